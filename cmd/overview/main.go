@@ -0,0 +1,64 @@
+// Command overview writes the top-down overview of a VXL map to a PNG file,
+// one pixel per column.
+package main
+
+import (
+	"flag"
+	"image/png"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/pudelkoM/go-render/pkg/blockworld"
+	"github.com/pudelkoM/go-render/pkg/maploader"
+	"github.com/pudelkoM/go-render/pkg/render"
+)
+
+func main() {
+	mapPath := flag.String("map", "./maps/DragonsReach.vxl", "path of the .vxl map or .vox model to load")
+	out := flag.String("out", "overview.png", "path of the PNG file to write")
+	shade := flag.Bool("shade", false, "darken lower columns")
+	colormap := flag.String("colormap", "", "color columns by height instead of block color: viridis, plasma, magma or inferno")
+	flag.Parse()
+
+	var opts render.OverviewOptions
+	opts.Shade = *shade
+	switch *colormap {
+	case "":
+	case "viridis":
+		opts.Colormap = blockworld.ViridisClamp
+	case "plasma":
+		opts.Colormap = blockworld.PlasmaClamp
+	case "magma":
+		opts.Colormap = blockworld.MagmaClamp
+	case "inferno":
+		opts.Colormap = blockworld.InfernoClamp
+	default:
+		log.Fatalf("unknown colormap %q", *colormap)
+	}
+
+	world := blockworld.NewChunkedBlockworld()
+	var err error
+	if strings.HasSuffix(*mapPath, ".vox") {
+		err = maploader.LoadVox(*mapPath, world)
+	} else {
+		err = maploader.LoadMap(*mapPath, world)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	img := render.Overview(world, opts)
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
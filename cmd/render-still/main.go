@@ -0,0 +1,196 @@
+// Command render-still renders a single frame of a VXL map to a PNG file
+// without opening a window.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pudelkoM/go-render/pkg/blockworld"
+	"github.com/pudelkoM/go-render/pkg/maploader"
+	"github.com/pudelkoM/go-render/pkg/render"
+)
+
+// vec3Flag parses a "x,y,z" command line value.
+type vec3Flag blockworld.Vec3
+
+func (v *vec3Flag) String() string {
+	return fmt.Sprintf("%g,%g,%g", v.X, v.Y, v.Z)
+}
+
+func (v *vec3Flag) Set(s string) error {
+	_, err := fmt.Sscanf(s, "%g,%g,%g", &v.X, &v.Y, &v.Z)
+	return err
+}
+
+// angle3Flag parses a "theta,phi" command line value in degrees.
+type angle3Flag blockworld.Angle3
+
+func (a *angle3Flag) String() string {
+	return fmt.Sprintf("%g,%g", a.Theta, a.Phi)
+}
+
+func (a *angle3Flag) Set(s string) error {
+	_, err := fmt.Sscanf(s, "%g,%g", &a.Theta, &a.Phi)
+	return err
+}
+
+func main() {
+	opts := render.DefaultOptions()
+	pos := vec3Flag{X: 190, Y: 310, Z: 33}
+	dir := angle3Flag{Theta: 95, Phi: 325}
+
+	mapPath := flag.String("map", "./maps/DragonsReach.vxl", "path of the .vxl map or .vox model to load")
+	out := flag.String("out", "frame.png", "path of the PNG file to write")
+	width := flag.Int("width", 640, "frame width in pixels")
+	height := flag.Int("height", 480, "frame height in pixels; half of the width for equirectangular panoramas")
+	depth := flag.Bool("depth", false, "render the depth view instead of block colors")
+	aoView := flag.Bool("ao-view", false, "render only the ambient occlusion term instead of block colors")
+	flag.BoolVar(&opts.AmbientOcclusion, "ao", opts.AmbientOcclusion, "darken corners and edges by ambient occlusion")
+	reflections := flag.Bool("reflections", false, "mirror the scene in reflective blocks such as water")
+	reflectivity := flag.Float64("reflectivity", render.DefaultReflectivity, "share of the mirrored color on reflective blocks with -reflections, 0 to 1")
+	bounces := flag.Int("bounces", render.DefaultMaxBounces, "maximum number of reflections a ray follows with -reflections")
+	sun := angle3Flag(opts.Sun)
+	flag.BoolVar(&opts.Lighting, "lighting", opts.Lighting, "shade block faces by the direction of the sun")
+	flag.Var(&sun, "sun", "direction towards the sun as theta,phi in degrees")
+	flag.Float64Var(&opts.Ambient, "ambient", opts.Ambient, "brightness of faces turned away from the sun, 0 to 1")
+	flag.BoolVar(&opts.Shadows, "shadows", opts.Shadows, "cast shadows with -lighting")
+	flag.Float64Var(&opts.ShadowDistance, "shadow-distance", opts.ShadowDistance, "maximum distance in blocks of a block casting a shadow")
+	strict := flag.Bool("strict", false, "reject malformed maps instead of loading what decodes")
+	modelPath := flag.String("model", "", "path of a .kv6 or .kvx model, such as a player or weapon, to place into the map")
+	var modelPos vec3Flag
+	flag.Var(&modelPos, "model-pos", "position of the pivot of -model as x,y,z; 10 blocks in front of the camera by default")
+	octree := flag.Bool("octree", false, "traverse an octree instead of the voxel grid")
+	flag.Var(&pos, "pos", "camera position as x,y,z")
+	flag.Var(&dir, "dir", "camera direction as theta,phi in degrees")
+	fov := flag.Float64("fov", render.DefaultFovHDeg, "horizontal field of view in degrees")
+	projection := flag.String("projection", "perspective", "camera projection: perspective, rectilinear, orthographic, isometric or equirectangular")
+	zoom := flag.Float64("zoom", 1, "block size in pixels for the orthographic and isometric projections")
+	cubemap := flag.String("cubemap", "", "render the six faces of a cubemap from -pos, with -width as their size: \"faces\" writes one file per face next to -out, \"cross\" all of them to -out")
+	flag.IntVar(&opts.MaxSteps, "max-steps", opts.MaxSteps, "maximum number of voxels a ray traverses")
+	flag.IntVar(&opts.Threads, "threads", opts.Threads, "number of render goroutines")
+	flag.Parse()
+
+	var proj render.Projection
+	switch *projection {
+	case "perspective":
+		proj = render.ProjectionPerspective
+	case "rectilinear":
+		proj = render.ProjectionRectilinear
+	case "orthographic":
+		proj = render.ProjectionOrthographic
+	case "isometric":
+		proj = render.ProjectionIsometric
+	case "equirectangular":
+		proj = render.ProjectionEquirectangular
+		heightSet := false
+		flag.Visit(func(f *flag.Flag) { heightSet = heightSet || f.Name == "height" })
+		if !heightSet {
+			*height = *width / 2
+		}
+	default:
+		log.Fatalf("unknown projection %q", *projection)
+	}
+	if *width <= 0 || *height <= 0 {
+		log.Fatalf("invalid frame size %dx%d", *width, *height)
+	}
+	if *cubemap != "" && *cubemap != "faces" && *cubemap != "cross" {
+		log.Fatalf("unknown cubemap layout %q", *cubemap)
+	}
+	if *cubemap != "" {
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "height" {
+				log.Fatal("-height does not apply to -cubemap, the faces are -width pixels square")
+			}
+		})
+	}
+	if *zoom <= 0 {
+		log.Fatalf("invalid zoom %g", *zoom)
+	}
+	if *depth {
+		opts.Mode = render.ModeDepth
+	}
+	if *aoView {
+		opts.Mode = render.ModeAO
+	}
+	opts.Sun = blockworld.Angle3(sun)
+	if *reflections {
+		opts.Reflectivity = *reflectivity
+		opts.MaxBounces = *bounces
+	}
+	if *octree {
+		opts.Traversal = render.TraversalOctree
+	}
+
+	world := blockworld.NewChunkedBlockworld()
+	mapOpts := maploader.DefaultOptions()
+	mapOpts.Strict = *strict
+	var err error
+	if strings.HasSuffix(*mapPath, ".vox") {
+		err = maploader.LoadVox(*mapPath, world)
+	} else {
+		err = maploader.LoadMapWithOptions(*mapPath, world, mapOpts)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *modelPath != "" {
+		load := maploader.LoadKV6
+		if strings.HasSuffix(*modelPath, ".kvx") {
+			load = maploader.LoadKVX
+		}
+		model, err := load(*modelPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		at := blockworld.Vec3(pos).Add(blockworld.Angle3(dir).ToCartesianVec3(10))
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "model-pos" {
+				at = blockworld.Vec3(modelPos)
+			}
+		})
+		model.Place(world, at)
+	}
+
+	camera := render.Camera{
+		Pos:        blockworld.Vec3(pos),
+		Dir:        blockworld.Angle3(dir),
+		Projection: proj,
+		FovHDeg:    *fov,
+		Zoom:       *zoom,
+	}
+	r := render.NewRenderer(world, camera, opts)
+
+	switch *cubemap {
+	case "faces":
+		// frame.png becomes frame_px.png, frame_nx.png and so on.
+		ext := filepath.Ext(*out)
+		for f, img := range r.Cubemap(camera.Pos, *width) {
+			writePNG(fmt.Sprintf("%s_%v%s", strings.TrimSuffix(*out, ext), render.CubeFace(f), ext), img)
+		}
+	case "cross":
+		writePNG(*out, render.CubemapCross(r.Cubemap(camera.Pos, *width)))
+	default:
+		writePNG(*out, r.Frame(*width, *height))
+	}
+}
+
+func writePNG(path string, img image.Image) {
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
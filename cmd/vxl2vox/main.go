@@ -0,0 +1,52 @@
+// Command vxl2vox converts a VXL map, or a region of it, to a MagicaVoxel
+// .vox file.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/pudelkoM/go-render/pkg/blockworld"
+	"github.com/pudelkoM/go-render/pkg/maploader"
+)
+
+// pointFlag parses a "x,y,z" command line value.
+type pointFlag blockworld.Point
+
+func (p *pointFlag) String() string {
+	return fmt.Sprintf("%d,%d,%d", p.X, p.Y, p.Z)
+}
+
+func (p *pointFlag) Set(s string) error {
+	_, err := fmt.Sscanf(s, "%d,%d,%d", &p.X, &p.Y, &p.Z)
+	return err
+}
+
+func main() {
+	var lo, hi pointFlag
+	mapPath := flag.String("map", "./maps/DragonsReach.vxl", "path of the .vxl map to convert")
+	out := flag.String("out", "map.vox", "path of the .vox file to write")
+	quantizer := flag.String("quantizer", "mediancut", "palette quantizer, mediancut or popularity")
+	flag.Var(&lo, "min", "first block of the exported region as x,y,z")
+	flag.Var(&hi, "max", "end of the exported region as x,y,z, exclusive; default is the whole map")
+	flag.Parse()
+
+	opts := maploader.VoxOptions{Min: blockworld.Point(lo), Max: blockworld.Point(hi)}
+	switch *quantizer {
+	case "mediancut":
+		opts.Quantizer = maploader.MedianCutQuantizer{}
+	case "popularity":
+		opts.Quantizer = maploader.PopularityQuantizer{}
+	default:
+		log.Fatalf("unknown quantizer %q", *quantizer)
+	}
+
+	world := blockworld.NewChunkedBlockworld()
+	if err := maploader.LoadMap(*mapPath, world); err != nil {
+		log.Fatal(err)
+	}
+	if err := maploader.SaveVox(*out, world, opts); err != nil {
+		log.Fatal(err)
+	}
+}
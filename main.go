@@ -4,22 +4,21 @@ import (
 	"fmt"
 	"image"
 	"image/color"
-	"image/draw"
+	"image/png"
 	"log"
-	"math"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
 	"runtime"
 	"slices"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/go-gl/gl/all-core/gl"
 	"github.com/go-gl/glfw/v3.3/glfw"
 	"github.com/pudelkoM/go-render/pkg/blockworld"
 	"github.com/pudelkoM/go-render/pkg/maploader"
+	"github.com/pudelkoM/go-render/pkg/render"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/basicfont"
 	"golang.org/x/image/math/fixed"
@@ -31,17 +30,22 @@ func init() {
 	runtime.LockOSThread()
 }
 
-const (
-	renderNormal = iota
-	renderDepth
-)
-
 var (
-	mapIndex   = 0
-	renderMode = renderNormal
+	mapIndex = 0
+	// keysDown holds the toggle keys that were held during the previous frame.
+	keysDown = map[glfw.Key]bool{}
 )
 
-func handleInputs(w *glfw.Window, world *blockworld.Blockworld) {
+// pressed reports whether key went down since the previous frame, so toggles
+// fire once per press, not on every frame the key is held.
+func pressed(w *glfw.Window, key glfw.Key) bool {
+	down := w.GetKey(key) == glfw.Press
+	wasDown := keysDown[key]
+	keysDown[key] = down
+	return down && !wasDown
+}
+
+func handleInputs(w *glfw.Window, world *blockworld.Blockworld, r *render.Renderer) {
 	if w.GetKey(glfw.KeyEscape) == glfw.Press {
 		w.SetShouldClose(true)
 	}
@@ -86,123 +90,93 @@ func handleInputs(w *glfw.Window, world *blockworld.Blockworld) {
 			log.Fatal(err)
 		}
 		files = slices.DeleteFunc(files, func(f os.DirEntry) bool {
-			return !strings.HasSuffix(f.Name(), ".vxl")
+			return !strings.HasSuffix(f.Name(), ".vxl") && !strings.HasSuffix(f.Name(), ".vox")
 		})
 		mapIndex = ((mapIndex + 1) % len(files))
 		fmt.Println("loading map", files[mapIndex].Name())
-		err = maploader.LoadMap(dir+files[mapIndex].Name(), world)
+		// Load into a fresh world, so a corrupt map leaves the current one intact.
+		next := blockworld.NewChunkedBlockworld()
+		if strings.HasSuffix(files[mapIndex].Name(), ".vox") {
+			err = maploader.LoadVox(dir+files[mapIndex].Name(), next)
+		} else {
+			err = maploader.LoadMap(dir+files[mapIndex].Name(), next)
+		}
 		if err != nil {
-			panic(err)
+			log.Println("loading map failed:", err)
+			return
 		}
+		next.PlayerPos = world.PlayerPos
+		next.PlayerDir = world.PlayerDir
+		*world = *next
+		r.Octree = nil
+	}
+	if pressed(w, glfw.KeyL) {
+		r.Options.Mode = (r.Options.Mode + 1) % (render.ModeAO + 1)
 	}
-	if w.GetKey(glfw.KeyL) == glfw.Press {
-		if renderMode == renderNormal {
-			renderMode = renderDepth
+	if pressed(w, glfw.KeyI) {
+		r.Options.Lighting = !r.Options.Lighting
+	}
+	if pressed(w, glfw.KeyH) {
+		r.Options.Shadows = !r.Options.Shadows
+	}
+	if pressed(w, glfw.KeyJ) {
+		r.Options.AmbientOcclusion = !r.Options.AmbientOcclusion
+	}
+	if pressed(w, glfw.KeyR) {
+		if r.Options.MaxBounces > 0 {
+			r.Options.Reflectivity, r.Options.MaxBounces = 0, 0
 		} else {
-			renderMode = renderNormal
+			r.Options.Reflectivity, r.Options.MaxBounces = render.DefaultReflectivity, render.DefaultMaxBounces
 		}
 	}
-}
-
-func castRayAmatidesWoo(img *image.RGBA, world *blockworld.Blockworld,
-	x, y int, rayPos, rayDir blockworld.Vec3) {
-	const maxStep = 250
-
-	fn := func(pos, dir float64) (int, float64, float64) {
-		if dir > 0 {
-			return 1, 1 / dir, (math.Floor(pos+1) - pos) / dir
-		} else if dir < 0 {
-			return -1, 1 / -dir, (math.Ceil(pos-1) - pos) / dir
-		} else {
-			return 0, 0, math.Inf(1)
+	if pressed(w, glfw.KeyP) {
+		r.Camera.Projection = (r.Camera.Projection + 1) % (render.ProjectionIsometric + 1)
+	}
+	if w.GetKey(glfw.KeyEqual) == glfw.Press || w.GetKey(glfw.KeyEqual) == glfw.Repeat {
+		r.Camera.ZoomBy(1.05)
+	}
+	if w.GetKey(glfw.KeyMinus) == glfw.Press || w.GetKey(glfw.KeyMinus) == glfw.Repeat {
+		r.Camera.ZoomBy(1 / 1.05)
+	}
+	if pressed(w, glfw.KeyK) {
+		if err := savePanorama(r); err != nil {
+			log.Println("saving panorama failed:", err)
 		}
 	}
-
-	stepX, tDeltaX, tMaxX := fn(rayPos.X, rayDir.X)
-	stepY, tDeltaY, tMaxY := fn(rayPos.Y, rayDir.Y)
-	stepZ, tDeltaZ, tMaxZ := fn(rayPos.Z, rayDir.Z)
-
-	for i := 0; i < maxStep; i++ {
-		if tMaxX < tMaxY && tMaxX < tMaxZ {
-			// Idea: store signed distance to nearest block per block
-			// in world map and use it to skip empty space faster.
-			rayPos.X += float64(stepX)
-			tMaxX += tDeltaX
-		} else if tMaxY < tMaxZ {
-			rayPos.Y += float64(stepY)
-			tMaxY += tDeltaY
+	if pressed(w, glfw.KeyO) {
+		if r.Options.Traversal == render.TraversalGrid {
+			r.Options.Traversal = render.TraversalOctree
 		} else {
-			rayPos.Z += float64(stepZ)
-			tMaxZ += tDeltaZ
-		}
-
-		n := rayPos.ToPointTrunc()
-		b, ok := world.Get(n)
-		if !ok {
-			// Advance vector to next full block?
-			continue
+			r.Options.Traversal = render.TraversalGrid
 		}
+	}
+}
 
-		// Color-space conversion without interfaces and heap allocations.
-		{
-			r, g, b, a := b.Color.RGBA()
-			c := color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
-			img.SetRGBA(x, y, c)
-		}
+// savePanorama writes a 360° panorama from the current camera position to a
+// PNG file in the working directory.
+func savePanorama(r *render.Renderer) error {
+	pano := *r
+	pano.Camera.Projection = render.ProjectionEquirectangular
+	img := pano.Frame(2048, 1024)
 
-		if renderMode == renderDepth {
-			v := blockworld.MagmaClamp(float64(i) / maxStep)
-			c := color.RGBA{
-				R: uint8(v.X * 255),
-				G: uint8(v.Y * 255),
-				B: uint8(v.Z * 255),
-				A: 255,
-			}
-			img.SetRGBA(x, y, c)
-		}
-		break
+	name := fmt.Sprintf("panorama-%d.png", time.Now().Unix())
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
 	}
+	fmt.Println("saved", name)
+	return f.Close()
 }
 
-func renderBuf(img *image.RGBA, world *blockworld.Blockworld, frameCount int64,
-	lastFrameDuration time.Duration) {
-	// clear image
-	draw.Draw(img, img.Rect, image.NewUniform(color.Black), image.Point{}, draw.Src)
-
-	imgRatio := float64(img.Rect.Dy()) / float64(img.Rect.Dx())
-	fovHDeg := 55.
-	fovVDeg := fovHDeg * imgRatio
-	degPerPixel := fovHDeg / float64(img.Rect.Dx())
-
-	const threads = 4
-	yDD := int(math.Ceil(float64(img.Rect.Dy()) / threads))
-	wg := sync.WaitGroup{}
-	wg.Add(threads)
-	for t := 0; t < threads; t++ {
-		go func(t int) {
-			defer wg.Done()
-			yStart := t * yDD
-			if yStart >= img.Rect.Dy() {
-				return
-			}
-			yEnd := (t + 1) * yDD
-			if yEnd >= img.Rect.Dy() {
-				yEnd = img.Rect.Dy()
-			}
-
-			for y := yStart; y < yEnd; y++ {
-				yd := (-fovVDeg / 2) + float64(y)*degPerPixel
-				for x := 0; x < img.Rect.Dx(); x++ {
-					xd := (-fovHDeg / 2) + float64(x)*degPerPixel
-					rayVec := blockworld.Vec3{X: 1, Y: 0, Z: 0}.
-						RotateY(yd).RotateZ(xd).
-						RotateY(world.PlayerDir.Theta - 90).RotateZ(world.PlayerDir.Phi)
-					castRayAmatidesWoo(img, world, x, y, world.PlayerPos, rayVec)
-				}
-			}
-		}(t)
-	}
-	wg.Wait()
+func renderBuf(img *image.RGBA, r *render.Renderer, world *blockworld.Blockworld,
+	frameCount int64, lastFrameDuration time.Duration) {
+	r.Camera.Pos = world.PlayerPos
+	r.Camera.Dir = world.PlayerDir
+	r.Render(img)
 
 	img.SetRGBA(img.Rect.Dx()/2, img.Rect.Dy()/2, color.RGBA{R: 255, A: 255})
 
@@ -277,7 +251,7 @@ func main() {
 	fmt.Println("frame size", img.Rect)
 
 	// World setup
-	world := blockworld.NewBlockworld()
+	world := blockworld.NewChunkedBlockworld()
 	// err = maploader.LoadMap("./maps/AttackonDeuces.vxl", world)
 	err = maploader.LoadMap("./maps/DragonsReach.vxl", world)
 	if err != nil {
@@ -294,6 +268,8 @@ func main() {
 	// world.PlayerPos = blockworld.Vec3{X: 154, Y: 256.5, Z: 40}
 	// world.PlayerDir = blockworld.Angle3{Theta: 90, Phi: 0}
 
+	renderer := render.NewRenderer(world, render.Camera{}, render.DefaultOptions())
+
 	var frameCount int64 = 0
 	var lastFrame = time.Now()
 	var lastFrameDuration time.Duration = 0
@@ -302,8 +278,8 @@ func main() {
 	var lastFrameTime = time.Now()
 
 	for !window.ShouldClose() {
-		handleInputs(window, world)
-		renderBuf(img, world, frameCount, lastFrameDuration)
+		handleInputs(window, world, renderer)
+		renderBuf(img, renderer, world, frameCount, lastFrameDuration)
 
 		gl.BindTexture(gl.TEXTURE_2D, texture)
 		gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA8, int32(w), int32(h), 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(img.Pix))
@@ -3,6 +3,7 @@ package blockworld
 import (
 	"fmt"
 	"image/color"
+	"iter"
 	"math"
 	"math/rand"
 )
@@ -137,6 +138,11 @@ func (v Vec3) Mul(s float64) Vec3 {
 	}
 }
 
+// Dot returns the dot product of v and v2.
+func (v Vec3) Dot(v2 Vec3) float64 {
+	return v.X*v2.X + v.Y*v2.Y + v.Z*v2.Z
+}
+
 func (v Vec3) Rotate(x, y, z float64) Vec3 {
 	xRad := x * math.Pi / 180
 	yRad := y * math.Pi / 180
@@ -222,6 +228,16 @@ func (v Vec3) ToPointTrunc() Point {
 	}
 }
 
+// ToPointFloor returns the block containing v, also for negative
+// coordinates, where ToPointTrunc rounds towards 0.
+func (v Vec3) ToPointFloor() Point {
+	return Point{
+		X: int(math.Floor(v.X)),
+		Y: int(math.Floor(v.Y)),
+		Z: int(math.Floor(v.Z)),
+	}
+}
+
 func SignAsFloat(f float64) float64 {
 	return math.Float64frombits(math.Float64bits(f)&(1<<63) | 0x3FF0000000000000)
 }
@@ -265,16 +281,36 @@ func (v Vec3) AdvanceToNextBlockBoundary(dir Vec3) Vec3 {
 	}
 }
 
+// BlockFlags hold the properties of a Block in one byte, which keeps Block
+// at 8 bytes.
+type BlockFlags uint8
+
+const (
+	FlagSet        BlockFlags = 1 << iota // a zero-value block is not set, i.e. air
+	FlagReflective                        // mirrors the scene, see render.Options.Reflectivity
+	// FlagTransparent blocks let rays and sunlight through, with the alpha of
+	// Color as opacity instead of shading. Loaders leave it unset: the only
+	// candidate, the water of VXL maps, is their bottom layer with nothing
+	// below it to see.
+	FlagTransparent
+)
+
 type Block struct {
 	Color                  color.NRGBA
-	IsSet                  bool // a zero-value block is not set, i.e. air
-	Reflective             bool
+	Flags                  BlockFlags
 	DistanceToNearestBlock int16
 }
 
+func (b Block) IsSet() bool       { return b.Flags&FlagSet != 0 }
+func (b Block) Reflective() bool  { return b.Flags&FlagReflective != 0 }
+func (b Block) Transparent() bool { return b.Flags&FlagTransparent != 0 }
+
 type Blockworld struct {
 	blocks      []Block
+	chunks      *chunks // replaces blocks in chunked worlds
 	x, y, z     int
+	distances   bool  // whether Set and Remove update distances
+	maxDistance int16 // upper bound of all stored distances
 	BlockSizePx int
 	PlayerPos   Vec3
 	PlayerDir   Angle3
@@ -289,7 +325,24 @@ func NewBlockworld() *Blockworld {
 	}
 }
 
+// NewChunkedBlockworld returns a world that stores its blocks in chunks of
+// 16x16x16, allocated on the first Set into them. Worlds that are mostly air
+// need a fraction of the memory of the dense layout of NewBlockworld, at a
+// small cost per Get.
+func NewChunkedBlockworld() *Blockworld {
+	bw := NewBlockworld()
+	bw.blocks = nil
+	bw.chunks = &chunks{}
+	return bw
+}
+
 func (bw *Blockworld) Randomize() {
+	bw.RandomizeWith(rand.New(rand.NewSource(rand.Int63())))
+}
+
+// RandomizeWith is like Randomize but draws the block colors from rng, so a
+// fixed seed always produces the same world.
+func (bw *Blockworld) RandomizeWith(rng *rand.Rand) {
 	const worldSize = 40
 	colors := []color.NRGBA{
 		color.NRGBA{0, 0, 0, 0},       // white
@@ -305,7 +358,7 @@ func (bw *Blockworld) Randomize() {
 	for x := 4; x < 5; x++ {
 		for y := -5; y < 5; y++ {
 			for z := 0; z < 5; z++ {
-				bw.Set(x, y, z, Block{Color: colors[rand.Intn(len(colors))]})
+				bw.Set(x, y, z, Block{Color: colors[rng.Intn(len(colors))]})
 			}
 		}
 	}
@@ -315,33 +368,102 @@ func (bw *Blockworld) SetSize(x, y, z int) {
 	bw.x = x
 	bw.y = y
 	bw.z = z
+	bw.distances = false
+	bw.maxDistance = 0
+	if bw.chunks != nil {
+		bw.chunks.setSize(x, y, z)
+		return
+	}
 	bw.blocks = make([]Block, x*y*z)
 }
 
+// Size returns the world dimensions set by SetSize.
+func (bw *Blockworld) Size() (x, y, z int) {
+	return bw.x, bw.y, bw.z
+}
+
+// Blocks returns all blocks of a dense world, indexed by
+// x + y*sizeX + z*sizeX*sizeY. It is nil for chunked worlds, use All to
+// iterate over the blocks of either storage.
 func (bw *Blockworld) Blocks() []Block {
 	return bw.blocks
 }
 
+// All returns an iterator over the positions and blocks of all stored
+// blocks: every block of dense worlds, and those in allocated chunks of
+// chunked worlds. Air in unallocated chunks is skipped.
+func (bw *Blockworld) All() iter.Seq2[Point, *Block] {
+	return func(yield func(Point, *Block) bool) {
+		if bw.chunks != nil {
+			bw.chunks.all(bw.x, bw.y, bw.z, yield)
+			return
+		}
+		i := 0
+		for z := 0; z < bw.z; z++ {
+			for y := 0; y < bw.y; y++ {
+				for x := 0; x < bw.x; x++ {
+					if !yield(Point{X: x, Y: y, Z: z}, &bw.blocks[i]) {
+						return
+					}
+					i++
+				}
+			}
+		}
+	}
+}
+
 func (bw *Blockworld) Get(p Point) (*Block, bool) {
 	if (p.X < 0 || p.X >= bw.x) || (p.Y < 0 || p.Y >= bw.y) || (p.Z < 0 || p.Z >= bw.z) {
 		return nil, false
 	}
+	if bw.chunks != nil {
+		return bw.chunks.get(p.X, p.Y, p.Z)
+	}
 	b := &bw.blocks[p.X+p.Y*bw.x+p.Z*bw.x*bw.y]
-	return b, b.IsSet
+	return b, b.IsSet()
 }
 
+// GetRaw is like Get. In chunked worlds, air in chunks that were never set
+// is returned as a shared zero Block, which must not be modified. Its
+// DistanceToNearestBlock is 0, see Distance for the actual one.
 func (bw *Blockworld) GetRaw(x, y, z int) (*Block, bool) {
 	if (x < 0 || x >= bw.x) || (y < 0 || y >= bw.y) || (z < 0 || z >= bw.z) {
 		return nil, false
 	}
+	if bw.chunks != nil {
+		return bw.chunks.get(x, y, z)
+	}
 	b := &bw.blocks[x+y*bw.x+z*bw.x*bw.y]
-	return b, b.IsSet
+	return b, b.IsSet()
 }
 
 func (bw *Blockworld) Set(x, y, z int, b Block) {
 	if (x < 0 || x >= bw.x) || (y < 0 || y >= bw.y) || (z < 0 || z >= bw.z) {
 		return
 	}
-	b.IsSet = true
-	bw.blocks[x+y*bw.x+z*bw.x*bw.y] = b
+	wasSet := false
+	if bw.distances {
+		_, wasSet = bw.GetRaw(x, y, z)
+	}
+	b.Flags |= FlagSet
+	b.DistanceToNearestBlock = 0
+	if bw.chunks != nil {
+		bw.chunks.set(x, y, z, b)
+	} else {
+		bw.blocks[x+y*bw.x+z*bw.x*bw.y] = b
+	}
+	if bw.distances && !wasSet {
+		bw.blockAdded(x, y, z)
+	}
+}
+
+// Remove turns the block at x, y, z into air.
+func (bw *Blockworld) Remove(x, y, z int) {
+	if _, ok := bw.GetRaw(x, y, z); !ok {
+		return
+	}
+	*bw.stored(x, y, z) = Block{}
+	if bw.distances {
+		bw.blockRemoved(x, y, z)
+	}
 }
@@ -4,7 +4,9 @@ import (
 	"image"
 	"image/color"
 	"math"
+	"math/rand"
 	"testing"
+	"unsafe"
 
 	"github.com/pudelkoM/go-render/pkg/blockworld"
 	"github.com/pudelkoM/go-render/pkg/maploader"
@@ -300,3 +302,287 @@ func BenchmarkWorldGetBlock(b *testing.B) {
 		}
 	})
 }
+
+// BenchmarkWorldGetBlockStorage compares Get on a terrain map stored densely
+// and in chunks.
+func BenchmarkWorldGetBlockStorage(b *testing.B) {
+	for _, s := range storages {
+		world := s.new()
+		fillTerrain(world)
+		b.Run(s.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var p = blockworld.Point{X: i % 512, Y: (i / 7) % 512, Z: i % 64}
+				world.Get(p)
+			}
+		})
+	}
+}
+
+var storages = []struct {
+	name string
+	new  func() *blockworld.Blockworld
+}{
+	{"dense", blockworld.NewBlockworld},
+	{"chunked", blockworld.NewChunkedBlockworld},
+}
+
+// fillTerrain fills world with a 512x512x64 height map that leaves most of
+// the volume as air, like Ace of Spades maps.
+func fillTerrain(world *blockworld.Blockworld) {
+	world.SetSize(512, 512, 64)
+	for x := 0; x < 512; x++ {
+		for y := 0; y < 512; y++ {
+			h := 8 + int(8*math.Sin(float64(x)/20)+8*math.Cos(float64(y)/30))
+			for z := 0; z < h; z++ {
+				world.Set(x, y, z, blockworld.Block{Color: color.NRGBA{uint8(x), uint8(y), uint8(z), 255}})
+			}
+		}
+	}
+}
+
+// BenchmarkWorldStorage reports the memory of a terrain world as B/op.
+func BenchmarkWorldStorage(b *testing.B) {
+	for _, s := range storages {
+		b.Run(s.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				fillTerrain(s.new())
+			}
+		})
+	}
+}
+
+func TestBlockSize(t *testing.T) {
+	// Dense maps hold hundreds of MB of blocks, every byte counts.
+	if size := unsafe.Sizeof(blockworld.Block{}); size != 8 {
+		t.Errorf("Block is %d bytes, want 8", size)
+	}
+}
+
+func TestChunkedStorage(t *testing.T) {
+	// The size is not a multiple of the chunk size on purpose.
+	dense := blockworld.NewBlockworld()
+	chunked := blockworld.NewChunkedBlockworld()
+	dense.SetSize(40, 33, 20)
+	chunked.SetSize(40, 33, 20)
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		x, y, z := rng.Intn(44)-2, rng.Intn(37)-2, rng.Intn(24)-2
+		b := blockworld.Block{Color: color.NRGBA{uint8(i), uint8(i >> 8), 7, 255}}
+		if i%3 == 0 {
+			b.Flags = blockworld.FlagReflective
+		}
+		dense.Set(x, y, z, b)
+		chunked.Set(x, y, z, b)
+	}
+
+	for x := -1; x <= 40; x++ {
+		for y := -1; y <= 33; y++ {
+			for z := -1; z <= 20; z++ {
+				want, wantOk := dense.GetRaw(x, y, z)
+				got, gotOk := chunked.Get(blockworld.Point{X: x, Y: y, Z: z})
+				if gotOk != wantOk || (got == nil) != (want == nil) || (got != nil && *got != *want) {
+					t.Fatalf("block (%d, %d, %d) = %+v, %v, want %+v, %v", x, y, z, got, gotOk, want, wantOk)
+				}
+			}
+		}
+	}
+
+	// All visits every set block of both storages, and every block of the
+	// dense one in index order.
+	set := 0
+	for p, got := range chunked.All() {
+		want, _ := dense.GetRaw(p.X, p.Y, p.Z)
+		if want == nil || *got != *want {
+			t.Fatalf("All() yields %+v at %+v, want %+v", got, p, want)
+		}
+		if got.IsSet() {
+			set++
+		}
+	}
+	i := 0
+	for p, b := range dense.All() {
+		if b != &dense.Blocks()[i] || p != (blockworld.Point{X: i % 40, Y: i / 40 % 33, Z: i / (40 * 33)}) {
+			t.Fatalf("All() yields block %d at %+v", i, p)
+		}
+		if b.IsSet() {
+			set--
+		}
+		i++
+	}
+	if i != 40*33*20 || set != 0 {
+		t.Errorf("All() yields %d dense blocks and %d more set chunked ones, want %d and 0", i, set, 40*33*20)
+	}
+
+	empty := blockworld.NewChunkedBlockworld()
+	empty.SetSize(32, 32, 32)
+	empty.Set(1, 2, 3, blockworld.Block{})
+	if b, ok := empty.GetRaw(20, 20, 20); b == nil || ok || *b != (blockworld.Block{}) {
+		t.Errorf("air in an unallocated chunk = %+v, %v, want a zero block, false", b, ok)
+	}
+}
+
+// bruteDistance is the Chebyshev distance from (x, y, z) to the nearest set
+// block of world, or math.MaxInt16 if there is none.
+func bruteDistance(world *blockworld.Blockworld, x, y, z int) int16 {
+	sx, sy, sz := world.Size()
+	d := math.MaxInt16
+	for bx := 0; bx < sx; bx++ {
+		for by := 0; by < sy; by++ {
+			for bz := 0; bz < sz; bz++ {
+				if _, ok := world.GetRaw(bx, by, bz); ok {
+					d = min(d, max(abs(bx-x), abs(by-y), abs(bz-z)))
+				}
+			}
+		}
+	}
+	return int16(d)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func TestComputeDistances(t *testing.T) {
+	for _, s := range storages {
+		t.Run(s.name, func(t *testing.T) {
+			world := s.new()
+			world.SetSize(20, 23, 18)
+			rng := rand.New(rand.NewSource(1))
+			for i := 0; i < 12; i++ {
+				world.Set(rng.Intn(20), rng.Intn(23), rng.Intn(18), blockworld.Block{})
+			}
+			world.ComputeDistances()
+
+			for x := 0; x < 20; x++ {
+				for y := 0; y < 23; y++ {
+					for z := 0; z < 18; z++ {
+						want := bruteDistance(world, x, y, z)
+						got := world.Distance(blockworld.Point{X: x, Y: y, Z: z})
+						if b, ok := world.GetRaw(x, y, z); !ok && b.DistanceToNearestBlock == 0 {
+							// Air in an unallocated chunk only has a bound.
+							if got < 1 || got > want {
+								t.Fatalf("distance of (%d, %d, %d) = %d, want 1 to %d", x, y, z, got, want)
+							}
+							continue
+						}
+						if got != want {
+							t.Fatalf("distance of (%d, %d, %d) = %d, want %d", x, y, z, got, want)
+						}
+					}
+				}
+			}
+		})
+	}
+
+	// The bounds of unallocated chunks follow the distance to the nearest
+	// set block closely enough for the ray traversal to skip them.
+	chunked := blockworld.NewChunkedBlockworld()
+	chunked.SetSize(64, 16, 16)
+	chunked.Set(0, 8, 8, blockworld.Block{})
+	chunked.ComputeDistances()
+	for x := 16; x < 64; x++ {
+		got := chunked.Distance(blockworld.Point{X: x, Y: 8, Z: 8})
+		if got > int16(x) || got <= int16(x-16) || (x%16 == 0 && got != int16(x)) {
+			t.Errorf("distance of (%d, 8, 8) in an unallocated chunk = %d, want %d", x, got, x)
+		}
+	}
+
+	world := blockworld.NewBlockworld()
+	world.SetSize(3, 3, 3)
+	world.ComputeDistances()
+	if b, _ := world.GetRaw(1, 1, 1); b.DistanceToNearestBlock != math.MaxInt16 {
+		t.Errorf("distance in an empty world = %d, want %d", b.DistanceToNearestBlock, math.MaxInt16)
+	}
+}
+
+func BenchmarkComputeDistances(b *testing.B) {
+	world := blockworld.NewBlockworld()
+	fillTerrain(world)
+	b.ReportAllocs()
+	for b.Loop() {
+		world.ComputeDistances()
+	}
+}
+
+// distances returns the distance of every block in world.
+func distances(world *blockworld.Blockworld) []int16 {
+	sx, sy, sz := world.Size()
+	var ds []int16
+	for z := 0; z < sz; z++ {
+		for y := 0; y < sy; y++ {
+			for x := 0; x < sx; x++ {
+				ds = append(ds, world.Distance(blockworld.Point{X: x, Y: y, Z: z}))
+			}
+		}
+	}
+	return ds
+}
+
+func TestDistancesAfterEdits(t *testing.T) {
+	for _, s := range storages {
+		t.Run(s.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(2))
+			world := s.new()
+			world.SetSize(30, 26, 20)
+			for i := 0; i < 20; i++ {
+				world.Set(rng.Intn(30), rng.Intn(26), rng.Intn(20), blockworld.Block{})
+			}
+			world.ComputeDistances()
+
+			for i := 0; i < 300; i++ {
+				x, y, z := rng.Intn(30), rng.Intn(26), rng.Intn(20)
+				if _, ok := world.GetRaw(x, y, z); ok && rng.Intn(3) != 0 {
+					world.Remove(x, y, z)
+				} else {
+					world.Set(x, y, z, blockworld.Block{})
+				}
+
+				got := distances(world)
+				world.ComputeDistances()
+				want := distances(world)
+				for j := range want {
+					// Chunked worlds may underestimate distances in and
+					// near chunks allocated after ComputeDistances.
+					if got[j] != want[j] && (s.name == "dense" || got[j] > want[j]) {
+						t.Fatalf("edit %d: distance of block %d = %d, want %d", i, j, got[j], want[j])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRemove(t *testing.T) {
+	world := blockworld.NewBlockworld()
+	world.SetSize(4, 4, 4)
+	world.Set(1, 2, 3, blockworld.Block{Color: color.NRGBA{R: 1, A: 255}})
+	world.Remove(1, 2, 3)
+	if b, ok := world.GetRaw(1, 2, 3); ok || *b != (blockworld.Block{}) {
+		t.Errorf("removed block = %+v, %v, want air", b, ok)
+	}
+	world.Remove(-1, 0, 0) // outside of the world, must not panic
+}
+
+// BenchmarkDistanceUpdate measures digging out and restoring a surface
+// block of a terrain world while keeping its distances up to date.
+func BenchmarkDistanceUpdate(b *testing.B) {
+	world := blockworld.NewBlockworld()
+	fillTerrain(world)
+	world.ComputeDistances()
+	rng := rand.New(rand.NewSource(1))
+	for b.Loop() {
+		x, y, z := rng.Intn(512), rng.Intn(512), 63
+		for ; z > 0; z-- {
+			if _, ok := world.GetRaw(x, y, z); ok {
+				break
+			}
+		}
+		world.Remove(x, y, z)
+		world.Set(x, y, z, blockworld.Block{})
+	}
+}
@@ -0,0 +1,134 @@
+package blockworld
+
+import "math"
+
+const (
+	chunkBits = 4
+	chunkSize = 1 << chunkBits
+	chunkMask = chunkSize - 1
+)
+
+type chunk [chunkSize * chunkSize * chunkSize]Block
+
+// chunks is the sparse block storage of chunked worlds. Chunks that were
+// never set are nil and read as air.
+type chunks struct {
+	chunks     []*chunk
+	cx, cy, cz int   // world size in chunks
+	empty      Block // returned for blocks in nil chunks
+	// air is, for each nil chunk, a lower bound of the distances of its
+	// blocks, or 0 if unknown.
+	air []int16
+}
+
+func (c *chunks) setSize(x, y, z int) {
+	c.cx = (x + chunkMask) >> chunkBits
+	c.cy = (y + chunkMask) >> chunkBits
+	c.cz = (z + chunkMask) >> chunkBits
+	c.chunks = make([]*chunk, c.cx*c.cy*c.cz)
+	c.air = make([]int16, len(c.chunks))
+}
+
+// index returns the index of the chunk containing x, y, z.
+func (c *chunks) index(x, y, z int) int {
+	return (x >> chunkBits) + (y>>chunkBits)*c.cx + (z>>chunkBits)*c.cx*c.cy
+}
+
+// get expects x, y and z to be inside the world. Blocks in nil chunks are
+// returned as the shared empty block, see distance for their distances.
+func (c *chunks) get(x, y, z int) (*Block, bool) {
+	ch := c.chunks[c.index(x, y, z)]
+	if ch == nil {
+		return &c.empty, false
+	}
+	b := &ch[(x&chunkMask)|(y&chunkMask)<<chunkBits|(z&chunkMask)<<(2*chunkBits)]
+	return b, b.IsSet()
+}
+
+func (c *chunks) set(x, y, z int, b Block) {
+	i := c.index(x, y, z)
+	ch := c.chunks[i]
+	if ch == nil {
+		ch = new(chunk)
+		c.chunks[i] = ch
+		if m := c.air[i]; m != 0 {
+			// Keep what is known about the distances of the air.
+			for j := range ch {
+				ch[j].DistanceToNearestBlock = airDistance(m, j&chunkMask, j>>chunkBits&chunkMask, j>>(2*chunkBits))
+			}
+		}
+	}
+	ch[(x&chunkMask)|(y&chunkMask)<<chunkBits|(z&chunkMask)<<(2*chunkBits)] = b
+}
+
+// distance returns a lower bound of the distance of x, y, z in a nil chunk.
+func (c *chunks) distance(x, y, z int) int16 {
+	return airDistance(c.air[c.index(x, y, z)], x&chunkMask, y&chunkMask, z&chunkMask)
+}
+
+// airDistance returns a lower bound of the distance of the block at lx, ly,
+// lz in a chunk without set blocks, whose blocks are at least m away from
+// the nearest set one. Set blocks are outside of the chunk, so the distance
+// is at least m plus the way to the nearest face of the chunk.
+func airDistance(m int16, lx, ly, lz int) int16 {
+	if m == 0 {
+		return 0
+	}
+	e := min(lx, ly, lz, chunkMask-lx, chunkMask-ly, chunkMask-lz)
+	return int16(min(int(m)+e, math.MaxInt16))
+}
+
+// lowerAir lowers the bounds of the nil chunks to the distance of the set
+// block at x, y, z, for chunks closer than r.
+func (c *chunks) lowerAir(x, y, z, r int) {
+	for cz := max(z-r, 0) >> chunkBits; cz <= min((z+r)>>chunkBits, c.cz-1); cz++ {
+		for cy := max(y-r, 0) >> chunkBits; cy <= min((y+r)>>chunkBits, c.cy-1); cy++ {
+			for cx := max(x-r, 0) >> chunkBits; cx <= min((x+r)>>chunkBits, c.cx-1); cx++ {
+				i := cx + cy*c.cx + cz*c.cx*c.cy
+				if c.chunks[i] != nil {
+					continue
+				}
+				d := int16(max(
+					boxDistance(x, cx<<chunkBits), boxDistance(y, cy<<chunkBits), boxDistance(z, cz<<chunkBits)))
+				c.air[i] = min(c.air[i], d)
+			}
+		}
+	}
+}
+
+// boxDistance returns the distance from v to the chunk starting at lo along
+// one axis.
+func boxDistance(v, lo int) int {
+	return max(lo-v, v-(lo+chunkMask), 0)
+}
+
+// stored returns the block at x, y, z, or nil if its chunk is not allocated.
+func (c *chunks) stored(x, y, z int) *Block {
+	ch := c.chunks[c.index(x, y, z)]
+	if ch == nil {
+		return nil
+	}
+	return &ch[(x&chunkMask)|(y&chunkMask)<<chunkBits|(z&chunkMask)<<(2*chunkBits)]
+}
+
+// all calls yield for the blocks of all allocated chunks that lie inside of a
+// world of x*y*z blocks, until it returns false.
+func (c *chunks) all(x, y, z int, yield func(Point, *Block) bool) {
+	for i, ch := range c.chunks {
+		if ch == nil {
+			continue
+		}
+		ox := (i % c.cx) << chunkBits
+		oy := (i / c.cx % c.cy) << chunkBits
+		oz := (i / (c.cx * c.cy)) << chunkBits
+		for j := range ch {
+			p := Point{X: ox + j&chunkMask, Y: oy + j>>chunkBits&chunkMask, Z: oz + j>>(2*chunkBits)}
+			if p.X >= x || p.Y >= y || p.Z >= z {
+				continue
+			}
+			if !yield(p, &ch[j]) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package blockworld
+
+import "math"
+
+// ComputeDistances fills DistanceToNearestBlock of every air block with the
+// Chebyshev distance to the nearest set block: a block at distance d is the
+// center of a (2d-1)³ cube of air, which rays can cross without looking at
+// each block. Set blocks get 0, distances are capped at math.MaxInt16 and
+// blocks outside of the world count as air.
+//
+// Afterwards, Set and Remove keep the distances up to date by recomputing
+// only the neighborhood of the changed block. Chunked worlds only store
+// distances in allocated chunks, see Distance for the air in other chunks.
+func (bw *Blockworld) ComputeDistances() {
+	bw.maxDistance = 0
+	if bw.chunks != nil {
+		for i := range bw.chunks.air {
+			bw.chunks.air[i] = math.MaxInt16
+		}
+	}
+	bw.recomputeDistances(Point{}, Point{X: bw.x, Y: bw.y, Z: bw.z})
+	bw.distances = true
+}
+
+// Distance returns the distance to the nearest set block of the air block
+// at p. For air in unallocated chunks of chunked worlds, it returns a lower
+// bound from the distance of the whole chunk. It is 0 for set blocks, blocks
+// outside of the world and unknown distances.
+func (bw *Blockworld) Distance(p Point) int16 {
+	if p.X < 0 || p.X >= bw.x || p.Y < 0 || p.Y >= bw.y || p.Z < 0 || p.Z >= bw.z {
+		return 0
+	}
+	b := bw.stored(p.X, p.Y, p.Z)
+	switch {
+	case b == nil:
+		return bw.chunks.distance(p.X, p.Y, p.Z)
+	case b.IsSet():
+		return 0
+	}
+	return b.DistanceToNearestBlock
+}
+
+// stored returns the block at x, y, z inside of the world, or nil if it is
+// in an unallocated chunk.
+func (bw *Blockworld) stored(x, y, z int) *Block {
+	if bw.chunks != nil {
+		return bw.chunks.stored(x, y, z)
+	}
+	return &bw.blocks[x+y*bw.x+z*bw.x*bw.y]
+}
+
+// recomputeDistances computes the distances of the blocks in the box from lo
+// to hi, exclusive. It uses the distances of the blocks just outside of the
+// box, so these have to be up to date.
+func (bw *Blockworld) recomputeDistances(lo, hi Point) {
+	// The buffer has a border of one block on every side, so neighbors never
+	// need bounds checks.
+	px, py, pz := hi.X-lo.X+2, hi.Y-lo.Y+2, hi.Z-lo.Z+2
+	dist := make([]uint16, px*py*pz)
+	for z := 0; z < pz; z++ {
+		for y := 0; y < py; y++ {
+			for x := 0; x < px; x++ {
+				wx, wy, wz := lo.X+x-1, lo.Y+y-1, lo.Z+z-1
+				_, ok := bw.GetRaw(wx, wy, wz)
+				border := x == 0 || y == 0 || z == 0 || x == px-1 || y == py-1 || z == pz-1
+				inside := wx >= 0 && wx < bw.x && wy >= 0 && wy < bw.y && wz >= 0 && wz < bw.z
+				i := x + y*px + z*px*py
+				switch {
+				case ok:
+					dist[i] = 0
+				case inside && border:
+					dist[i] = uint16(bw.Distance(Point{X: wx, Y: wy, Z: wz}))
+				default:
+					dist[i] = math.MaxInt16
+				}
+			}
+		}
+	}
+
+	// A two pass chamfer transform over the 26-neighborhood with all weights
+	// 1 is exact for the Chebyshev distance. The first pass propagates from
+	// the 13 neighbors that come before a block in memory order, the second
+	// one from the 13 after it.
+	var before [13]int
+	n := 0
+	for dz := -1; dz <= 1; dz++ {
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				if off := dx + dy*px + dz*px*py; off < 0 {
+					before[n] = off
+					n++
+				}
+			}
+		}
+	}
+	for z := 1; z < pz-1; z++ {
+		for y := 1; y < py-1; y++ {
+			for i := 1 + y*px + z*px*py; i < px-1+y*px+z*px*py; i++ {
+				d := dist[i]
+				for _, off := range before {
+					d = min(d, dist[i+off]+1)
+				}
+				dist[i] = d
+			}
+		}
+	}
+	for z := pz - 2; z >= 1; z-- {
+		for y := py - 2; y >= 1; y-- {
+			for i := px - 2 + y*px + z*px*py; i >= 1+y*px+z*px*py; i-- {
+				d := dist[i]
+				for _, off := range before {
+					d = min(d, dist[i-off]+1)
+				}
+				dist[i] = d
+			}
+		}
+	}
+
+	for z := lo.Z; z < hi.Z; z++ {
+		for y := lo.Y; y < hi.Y; y++ {
+			for x := lo.X; x < hi.X; x++ {
+				d := int16(min(dist[(x-lo.X+1)+(y-lo.Y+1)*px+(z-lo.Z+1)*px*py], math.MaxInt16))
+				bw.maxDistance = max(bw.maxDistance, d)
+				b := bw.stored(x, y, z)
+				if b == nil {
+					i := bw.chunks.index(x, y, z)
+					bw.chunks.air[i] = min(bw.chunks.air[i], d)
+					continue
+				}
+				b.DistanceToNearestBlock = d
+			}
+		}
+	}
+}
+
+// blockAdded lowers the distances around the newly set block at x, y, z.
+func (bw *Blockworld) blockAdded(x, y, z int) {
+	if bw.chunks != nil {
+		bw.chunks.lowerAir(x, y, z, int(bw.maxDistance))
+	}
+	// Distances differ by at most 1 between neighbors, so if no block at
+	// distance r from the new one was further away from other blocks, none
+	// further out is either. Only blocks with unknown distances can break
+	// that chain.
+	for r := 1; r < int(bw.maxDistance); r++ {
+		more := false
+		bw.shell(x, y, z, r, func(b *Block) {
+			switch {
+			case b == nil || b.DistanceToNearestBlock == 0:
+				more = true
+			case int(b.DistanceToNearestBlock) > r:
+				b.DistanceToNearestBlock = int16(r)
+				more = true
+			}
+		})
+		if !more {
+			return
+		}
+	}
+}
+
+// blockRemoved recomputes the distances around the removed block at x, y, z.
+func (bw *Blockworld) blockRemoved(x, y, z int) {
+	// Only blocks that had the removed one as a nearest block can change.
+	// Like in blockAdded, they form a cube around it that ends at the first
+	// distance r without any of them.
+	r := 1
+	for ; r <= int(bw.maxDistance); r++ {
+		affected := false
+		bw.shell(x, y, z, r, func(b *Block) {
+			affected = affected || (b != nil && int(b.DistanceToNearestBlock) == r)
+		})
+		if !affected {
+			break
+		}
+	}
+	bw.recomputeDistances(
+		Point{X: max(x-r+1, 0), Y: max(y-r+1, 0), Z: max(z-r+1, 0)},
+		Point{X: min(x+r, bw.x), Y: min(y+r, bw.y), Z: min(z+r, bw.z)})
+}
+
+// shell calls fn for the air blocks inside of the world at a Chebyshev
+// distance of exactly r from x, y, z. Blocks in unallocated chunks are
+// passed as nil.
+func (bw *Blockworld) shell(x, y, z, r int, fn func(b *Block)) {
+	visit := func(x, y, z int) {
+		if b := bw.stored(x, y, z); b == nil || !b.IsSet() {
+			fn(b)
+		}
+	}
+	for sz := max(z-r, 0); sz <= min(z+r, bw.z-1); sz++ {
+		for sy := max(y-r, 0); sy <= min(y+r, bw.y-1); sy++ {
+			if sz == z-r || sz == z+r || sy == y-r || sy == y+r {
+				for sx := max(x-r, 0); sx <= min(x+r, bw.x-1); sx++ {
+					visit(sx, sy, sz)
+				}
+				continue
+			}
+			if x-r >= 0 {
+				visit(x-r, sy, sz)
+			}
+			if x+r < bw.x {
+				visit(x+r, sy, sz)
+			}
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package maploader
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+
+	"github.com/pudelkoM/go-render/pkg/blockworld"
+)
+
+// Model is a standalone voxel model, such as a player or weapon sprite, that
+// can be placed into a world.
+type Model struct {
+	Voxels *blockworld.Blockworld
+	// Pivot is the point the model rotates around, in block coordinates of
+	// Voxels.
+	Pivot blockworld.Vec3
+}
+
+// Place copies the set blocks of m into world so that the pivot ends up at
+// pos. Blocks outside of world are dropped.
+func (m *Model) Place(world *blockworld.Blockworld, pos blockworld.Vec3) {
+	off := pos.Sub(m.Pivot).ToNearestPoint()
+	sx, sy, sz := m.Voxels.Size()
+	for x := 0; x < sx; x++ {
+		for y := 0; y < sy; y++ {
+			for z := 0; z < sz; z++ {
+				if b, ok := m.Voxels.GetRaw(x, y, z); ok {
+					world.Set(x+off.X, y+off.Y, z+off.Z, *b)
+				}
+			}
+		}
+	}
+}
+
+// LoadKV6 reads a Voxlap .kv6 model, the format of Ace of Spades player and
+// weapon models.
+func LoadKV6(path string) (*Model, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeKV6(data)
+}
+
+// DecodeKV6 parses the .kv6 model in data. Like VXL maps, KV6 models store
+// z pointing down; the returned model has z pointing up like Blockworld.
+func DecodeKV6(data []byte) (*Model, error) {
+	const headerSize = 32
+	const voxelSize = 8
+	if len(data) < headerSize || string(data[:4]) != "Kvxl" {
+		return nil, errors.New("kv6: missing Kvxl header")
+	}
+	sx := int(int32(binary.LittleEndian.Uint32(data[4:])))
+	sy := int(int32(binary.LittleEndian.Uint32(data[8:])))
+	sz := int(int32(binary.LittleEndian.Uint32(data[12:])))
+	px := math.Float32frombits(binary.LittleEndian.Uint32(data[16:]))
+	py := math.Float32frombits(binary.LittleEndian.Uint32(data[20:]))
+	pz := math.Float32frombits(binary.LittleEndian.Uint32(data[24:]))
+	n := int(int32(binary.LittleEndian.Uint32(data[28:])))
+	if sx <= 0 || sy <= 0 || sz <= 0 || sx > 1024 || sy > 1024 || sz > 1024 {
+		return nil, fmt.Errorf("kv6: invalid size %dx%dx%d", sx, sy, sz)
+	}
+
+	voxels := data[headerSize:]
+	if n < 0 || n > len(voxels)/voxelSize {
+		return nil, fmt.Errorf("kv6: %d voxels exceed the data", n)
+	}
+	// The per-x counts are redundant with the per-column ones and skipped.
+	lengths := voxels[n*voxelSize:]
+	if len(lengths) < sx*4+sx*sy*2 {
+		return nil, errors.New("kv6: insufficient column data")
+	}
+	columns := lengths[sx*4:]
+
+	world := blockworld.NewChunkedBlockworld()
+	world.SetSize(sx, sy, sz)
+	i := 0
+	for x := 0; x < sx; x++ {
+		for y := 0; y < sy; y++ {
+			count := int(binary.LittleEndian.Uint16(columns[(x*sy+y)*2:]))
+			if i+count > n {
+				return nil, fmt.Errorf("kv6: column (%d, %d) exceeds the %d voxels", x, y, n)
+			}
+			for ; count > 0; count-- {
+				v := voxels[i*voxelSize:]
+				z := int(binary.LittleEndian.Uint16(v[4:]))
+				if z >= sz {
+					return nil, fmt.Errorf("kv6: voxel %d at z %d exceeds height %d", i, z, sz)
+				}
+				world.Set(x, y, sz-1-z, blockworld.Block{
+					// The fourth byte is a brightness, not opacity.
+					Color: color.NRGBA{B: v[0], G: v[1], R: v[2], A: 255},
+				})
+				i++
+			}
+		}
+	}
+
+	return &Model{
+		Voxels: world,
+		Pivot:  blockworld.Vec3{X: float64(px), Y: float64(py), Z: float64(sz) - float64(pz)},
+	}, nil
+}
+
+// LoadKVX reads a Build engine .kvx model.
+func LoadKVX(path string) (*Model, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeKVX(data)
+}
+
+// DecodeKVX parses the .kvx model in data. Only the first, full resolution
+// mip level is read. Colors come from the 256 entry palette at the end of
+// the file.
+func DecodeKVX(data []byte) (*Model, error) {
+	const paletteSize = 768
+	if len(data) < 28+paletteSize {
+		return nil, errors.New("kvx: insufficient data")
+	}
+	pal := data[len(data)-paletteSize:]
+	palette := func(i uint8) color.NRGBA {
+		// Palette channels range from 0 to 63.
+		return color.NRGBA{
+			R: uint8(int(pal[int(i)*3]) * 255 / 63),
+			G: uint8(int(pal[int(i)*3+1]) * 255 / 63),
+			B: uint8(int(pal[int(i)*3+2]) * 255 / 63),
+			A: 255,
+		}
+	}
+
+	numBytes := int(int32(binary.LittleEndian.Uint32(data)))
+	if numBytes < 24 || 4+numBytes > len(data)-paletteSize {
+		return nil, fmt.Errorf("kvx: invalid mip size %d", numBytes)
+	}
+	mip := data[4 : 4+numBytes]
+	header := func(i int) int { return int(int32(binary.LittleEndian.Uint32(mip[i*4:]))) }
+	sx, sy, sz := header(0), header(1), header(2)
+	// Pivots are 24.8 fixed point.
+	px, py, pz := float64(header(3))/256, float64(header(4))/256, float64(header(5))/256
+	if sx <= 0 || sy <= 0 || sz <= 0 || sx > 1024 || sy > 1024 || sz > 255 {
+		return nil, fmt.Errorf("kvx: invalid size %dx%dx%d", sx, sy, sz)
+	}
+
+	// Offsets are relative to the start of the x offset table.
+	offsets := mip[24:]
+	if len(offsets) < (sx+1)*4+sx*(sy+1)*2 {
+		return nil, errors.New("kvx: insufficient offset data")
+	}
+	xyOffsets := offsets[(sx+1)*4:]
+
+	world := blockworld.NewChunkedBlockworld()
+	world.SetSize(sx, sy, sz)
+	for x := 0; x < sx; x++ {
+		xOff := int(binary.LittleEndian.Uint32(offsets[x*4:]))
+		for y := 0; y < sy; y++ {
+			start := xOff + int(binary.LittleEndian.Uint16(xyOffsets[(x*(sy+1)+y)*2:]))
+			end := xOff + int(binary.LittleEndian.Uint16(xyOffsets[(x*(sy+1)+y+1)*2:]))
+			if start > end || end > len(offsets) {
+				return nil, fmt.Errorf("kvx: column (%d, %d) out of range", x, y)
+			}
+			// A column is a list of slabs: top z, length, cull flags and
+			// one palette index per voxel.
+			slabs := offsets[start:end]
+			for len(slabs) > 0 {
+				if len(slabs) < 3 || len(slabs) < 3+int(slabs[1]) {
+					return nil, fmt.Errorf("kvx: column (%d, %d) has a truncated slab", x, y)
+				}
+				top, length := int(slabs[0]), int(slabs[1])
+				if top+length > sz {
+					return nil, fmt.Errorf("kvx: column (%d, %d) exceeds height %d", x, y, sz)
+				}
+				for i := 0; i < length; i++ {
+					world.Set(x, y, sz-1-(top+i), blockworld.Block{Color: palette(slabs[3+i])})
+				}
+				slabs = slabs[3+length:]
+			}
+		}
+	}
+
+	return &Model{
+		Voxels: world,
+		Pivot:  blockworld.Vec3{X: px, Y: py, Z: float64(sz) - pz},
+	}, nil
+}
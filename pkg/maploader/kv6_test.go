@@ -0,0 +1,134 @@
+package maploader_test
+
+import (
+	"encoding/binary"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/pudelkoM/go-render/pkg/blockworld"
+	"github.com/pudelkoM/go-render/pkg/maploader"
+)
+
+// kv6Model encodes a 2x1x3 KV6 model with voxels at z 0 and 2 of column
+// (0, 0) and at z 1 of column (1, 0), in KV6 coordinates.
+func kv6Model() []byte {
+	out := []byte("Kvxl")
+	out = binary.LittleEndian.AppendUint32(out, 2)
+	out = binary.LittleEndian.AppendUint32(out, 1)
+	out = binary.LittleEndian.AppendUint32(out, 3)
+	for _, p := range []float32{1, 0.5, 2.5} {
+		out = binary.LittleEndian.AppendUint32(out, math.Float32bits(p))
+	}
+	out = binary.LittleEndian.AppendUint32(out, 3)
+	for _, v := range []struct {
+		b, g, r uint8
+		z       uint16
+	}{{10, 20, 30, 0}, {40, 50, 60, 2}, {70, 80, 90, 1}} {
+		out = append(out, v.b, v.g, v.r, 128)
+		out = binary.LittleEndian.AppendUint16(out, v.z)
+		out = append(out, 0, 0)
+	}
+	out = binary.LittleEndian.AppendUint32(out, 2) // x = 0
+	out = binary.LittleEndian.AppendUint32(out, 1) // x = 1
+	out = binary.LittleEndian.AppendUint16(out, 2) // (0, 0)
+	return binary.LittleEndian.AppendUint16(out, 1)
+}
+
+func TestDecodeKV6(t *testing.T) {
+	m, err := maploader.DecodeKV6(kv6Model())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x, y, z := m.Voxels.Size(); x != 2 || y != 1 || z != 3 {
+		t.Fatalf("size = %dx%dx%d, want 2x1x3", x, y, z)
+	}
+	if want := (blockworld.Vec3{X: 1, Y: 0.5, Z: 0.5}); m.Pivot != want {
+		t.Errorf("pivot = %v, want %v", m.Pivot, want)
+	}
+	for _, tt := range []struct {
+		x, z int
+		want color.NRGBA
+	}{
+		{0, 2, color.NRGBA{30, 20, 10, 255}},
+		{0, 0, color.NRGBA{60, 50, 40, 255}},
+		{1, 1, color.NRGBA{90, 80, 70, 255}},
+	} {
+		if c := blockColor(t, m.Voxels, tt.x, 0, tt.z); c != tt.want {
+			t.Errorf("block (%d, 0, %d) = %v, want %v", tt.x, tt.z, c, tt.want)
+		}
+	}
+	if _, ok := m.Voxels.GetRaw(0, 0, 1); ok {
+		t.Error("block (0, 0, 1) is set")
+	}
+
+	data := kv6Model()
+	if _, err := maploader.DecodeKV6(data[:len(data)-1]); err == nil {
+		t.Error("DecodeKV6() of a truncated model succeeded")
+	}
+}
+
+func TestDecodeKVX(t *testing.T) {
+	// A 1x2x4 model: column (0, 0) holds one slab of two voxels from z 1,
+	// column (0, 1) is empty.
+	slabs := []byte{1, 2, 0, 5, 6}
+	xOffsets := (1+1)*4 + 1*(2+1)*2
+	mip := binary.LittleEndian.AppendUint32(nil, 1)
+	mip = binary.LittleEndian.AppendUint32(mip, 2)
+	mip = binary.LittleEndian.AppendUint32(mip, 4)
+	mip = binary.LittleEndian.AppendUint32(mip, 128) // 0.5
+	mip = binary.LittleEndian.AppendUint32(mip, 256) // 1
+	mip = binary.LittleEndian.AppendUint32(mip, 768) // 3
+	mip = binary.LittleEndian.AppendUint32(mip, uint32(xOffsets))
+	mip = binary.LittleEndian.AppendUint32(mip, uint32(xOffsets+len(slabs)))
+	mip = binary.LittleEndian.AppendUint16(mip, 0)
+	mip = binary.LittleEndian.AppendUint16(mip, uint16(len(slabs)))
+	mip = binary.LittleEndian.AppendUint16(mip, uint16(len(slabs)))
+	mip = append(mip, slabs...)
+
+	palette := make([]byte, 768)
+	copy(palette[5*3:], []byte{63, 0, 0, 0, 63, 0})
+
+	data := binary.LittleEndian.AppendUint32(nil, uint32(len(mip)))
+	data = append(append(data, mip...), palette...)
+
+	m, err := maploader.DecodeKVX(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x, y, z := m.Voxels.Size(); x != 1 || y != 2 || z != 4 {
+		t.Fatalf("size = %dx%dx%d, want 1x2x4", x, y, z)
+	}
+	if want := (blockworld.Vec3{X: 0.5, Y: 1, Z: 1}); m.Pivot != want {
+		t.Errorf("pivot = %v, want %v", m.Pivot, want)
+	}
+	if c := blockColor(t, m.Voxels, 0, 0, 2); c != (color.NRGBA{255, 0, 0, 255}) {
+		t.Errorf("block (0, 0, 2) = %v, want red", c)
+	}
+	if c := blockColor(t, m.Voxels, 0, 0, 1); c != (color.NRGBA{0, 255, 0, 255}) {
+		t.Errorf("block (0, 0, 1) = %v, want green", c)
+	}
+	for _, p := range []blockworld.Point{{X: 0, Y: 0, Z: 0}, {X: 0, Y: 0, Z: 3}, {X: 0, Y: 1, Z: 1}} {
+		if _, ok := m.Voxels.Get(p); ok {
+			t.Errorf("block %v is set", p)
+		}
+	}
+}
+
+func TestModelPlace(t *testing.T) {
+	m, err := maploader.DecodeKV6(kv6Model())
+	if err != nil {
+		t.Fatal(err)
+	}
+	world := blockworld.NewBlockworld()
+	world.SetSize(10, 10, 10)
+	// The pivot (1, 0.5, 0.5) lands on (5, 5.5, 5.5), moving the model by
+	// (4, 5, 5).
+	m.Place(world, blockworld.Vec3{X: 5, Y: 5.5, Z: 5.5})
+	if c := blockColor(t, world, 4, 5, 7); c != (color.NRGBA{30, 20, 10, 255}) {
+		t.Errorf("block (4, 5, 7) = %v", c)
+	}
+	if c := blockColor(t, world, 5, 5, 6); c != (color.NRGBA{90, 80, 70, 255}) {
+		t.Errorf("block (5, 5, 6) = %v", c)
+	}
+}
@@ -3,144 +3,322 @@ package maploader
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"image/color"
+	"math"
 	"os"
 
 	"github.com/pudelkoM/go-render/pkg/blockworld"
 )
 
-var (
-	mapData   [512][512][64]int
-	colorData [512][512][64]uint32
-)
+// Options describes the layout of a VXL map. The format has no header, so
+// it cannot be read from the file itself.
+type Options struct {
+	Width  int // columns along x, 0 detects it from the number of columns
+	Depth  int // columns along y, 0 detects it from the number of columns
+	Height int // blocks per column, at most 256
+	// Strict rejects maps that decode but are not well formed, i.e. have
+	// overlapping spans or trailing bytes after the last column.
+	Strict bool
+}
+
+// DefaultOptions returns the layout of Ace of Spades maps.
+func DefaultOptions() Options {
+	return Options{
+		Width:  512,
+		Depth:  512,
+		Height: 64,
+	}
+}
+
+// DecodeError describes where decoding a VXL map failed.
+type DecodeError struct {
+	Offset int // byte offset into the map data
+	X, Y   int // column, or -1 if the error is not about a single column
+	Span   int // span within the column, or -1
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	if e.X < 0 {
+		return fmt.Sprintf("vxl: offset %d: %v", e.Offset, e.Err)
+	}
+	if e.Span < 0 {
+		return fmt.Sprintf("vxl: column (%d, %d) at offset %d: %v", e.X, e.Y, e.Offset, e.Err)
+	}
+	return fmt.Sprintf("vxl: column (%d, %d) span %d at offset %d: %v", e.X, e.Y, e.Span, e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// column is the decoded geometry and colors of one map column, indexed by
+// height with z = 0 at the bottom like in Blockworld.
+type column struct {
+	solid []bool
+	color []uint32
+}
+
+func newColumn(height int) *column {
+	return &column{
+		solid: make([]bool, height),
+		color: make([]uint32, height),
+	}
+}
 
 func LoadMap(path string, world *blockworld.Blockworld) error {
+	return LoadMapWithOptions(path, world, DefaultOptions())
+}
+
+func LoadMapWithOptions(path string, world *blockworld.Blockworld, opts Options) error {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return err
 	}
-	err = loadMap(data, len(data))
-	if err != nil {
+	return DecodeVXLWithOptions(data, world, opts)
+}
+
+// DecodeVXL fills world from the Ace of Spades VXL map in data and computes
+// its distance field. It is safe to call concurrently for different worlds.
+// On error, world is left unchanged.
+func DecodeVXL(data []byte, world *blockworld.Blockworld) error {
+	return DecodeVXLWithOptions(data, world, DefaultOptions())
+}
+
+// DecodeVXLWithOptions is like DecodeVXL for maps with the layout in opts.
+// A zero Width or Depth is detected from the number of columns in data,
+// assuming a square map if both are zero. The world is resized to the map.
+func DecodeVXLWithOptions(data []byte, world *blockworld.Blockworld, opts Options) error {
+	if opts.Height <= 0 || opts.Height > 256 {
+		return fmt.Errorf("invalid map height %d", opts.Height)
+	}
+	if opts.Width < 0 || opts.Depth < 0 {
+		return fmt.Errorf("invalid map size %dx%d", opts.Width, opts.Depth)
+	}
+	if opts.Width == 0 || opts.Depth == 0 {
+		var err error
+		opts.Width, opts.Depth, err = detectSize(data, opts)
+		if err != nil {
+			return err
+		}
+	} else if err := loadMap(data, nil, opts); err != nil {
+		// Check the data before allocating the world, so a size larger than
+		// the map fails instead of running out of memory.
 		return err
 	}
 
-	world.SetSize(512, 512, 64)
-
-	for x := 0; x < len(mapData); x++ {
-		for y := 0; y < len(mapData[x]); y++ {
-			for z := 0; z < len(mapData[x][y]); z++ {
-				if mapData[x][y][z] == 1 {
-					c := colorData[x][y][z]
-					col := color.NRGBA{
-						B: uint8((c >> 24) & 0xFF),
-						G: uint8((c >> 16) & 0xFF),
-						R: uint8((c >> 8) & 0xFF),
-						// Alpha is used for shading. 0x00 is dark. 0x80 is full brightness.
-						A: uint8(c&0xFF) + 128,
-						// A: uint8(c & 0xFF),
-					}
-					world.Set(x, y, z, blockworld.Block{
-						Color:      col,
-						Reflective: z == 0,
-					})
-				}
-			}
+	world.SetSize(opts.Width, opts.Depth, opts.Height)
+	if err := loadMap(data, world, opts); err != nil {
+		return err
+	}
+	world.ComputeDistances()
+	return nil
+}
+
+// detectSize fills in the zero dimensions of opts from the number of
+// columns in data.
+func detectSize(data []byte, opts Options) (int, int, error) {
+	n := 0
+	col := newColumn(opts.Height)
+	for off := 0; off < len(data); n++ {
+		var err error
+		off, err = col.decode(data, off, opts.Strict)
+		if err != nil {
+			return 0, 0, fmt.Errorf("detecting map size: column %d: %w", n, err)
 		}
 	}
 
-	return nil
+	switch {
+	case opts.Width == 0 && opts.Depth == 0:
+		side := int(math.Sqrt(float64(n)))
+		if side == 0 || side*side != n {
+			return 0, 0, fmt.Errorf("cannot detect map size: %d columns do not form a square", n)
+		}
+		return side, side, nil
+	case opts.Width == 0:
+		if n%opts.Depth != 0 {
+			return 0, 0, fmt.Errorf("cannot detect map width: %d columns do not fit depth %d", n, opts.Depth)
+		}
+		return n / opts.Depth, opts.Depth, nil
+	default:
+		if n%opts.Width != 0 {
+			return 0, 0, fmt.Errorf("cannot detect map depth: %d columns do not fit width %d", n, opts.Width)
+		}
+		return opts.Width, n / opts.Width, nil
+	}
 }
 
-func setGeom(x, y, z, t int) error {
-	z = 63 - z
-	if z < 0 || z >= 64 {
-		return errors.New("z out of bounds")
+// store copies the solid blocks of col into column (x, y) of world.
+func (col *column) store(world *blockworld.Blockworld, x, y int) {
+	for z := 0; z < len(col.solid); z++ {
+		if !col.solid[z] {
+			continue
+		}
+		c := col.color[z]
+		var flags blockworld.BlockFlags
+		if z == 0 {
+			flags = blockworld.FlagReflective
+		}
+		world.Set(x, y, z, blockworld.Block{
+			Color: color.NRGBA{
+				B: uint8((c >> 24) & 0xFF),
+				G: uint8((c >> 16) & 0xFF),
+				R: uint8((c >> 8) & 0xFF),
+				// Alpha is used for shading. 0x00 is dark. 0x80 is full brightness.
+				A: uint8(c&0xFF) + 128,
+				// A: uint8(c & 0xFF),
+			},
+			Flags: flags,
+		})
 	}
-	mapData[x][y][z] = t
-	return nil
 }
 
-func setColor(x, y, z int, c uint32) error {
-	z = 63 - z
-	if z < 0 || z >= 64 {
-		return errors.New("z out of bounds")
+// setGeom and setColor take z in VXL coordinates, where z = 0 is the top.
+
+func (col *column) setGeom(z int, solid bool) error {
+	if z < 0 || z >= len(col.solid) {
+		return fmt.Errorf("z %d exceeds map height %d", z, len(col.solid))
 	}
-	colorData[x][y][z] = c
+	col.solid[len(col.solid)-1-z] = solid
 	return nil
 }
 
-func loadMap(v []byte, length int) error {
-	// base := v
-	var x, y, z int
+func (col *column) setColor(z int, c uint32) error {
+	if z < 0 || z >= len(col.color) {
+		return fmt.Errorf("z %d exceeds map height %d", z, len(col.color))
+	}
+	col.color[len(col.color)-1-z] = c
+	return nil
+}
 
-	for y = 0; y < 512; y++ {
-		for x = 0; x < 512; x++ {
-			for z = 0; z < 64; z++ {
-				if err := setGeom(x, y, z, 1); err != nil {
-					return err
-				}
+// loadMap decodes the columns of data into world. With a nil world, it only
+// checks that data holds a map of the size in opts.
+func loadMap(data []byte, world *blockworld.Blockworld, opts Options) error {
+	col := newColumn(opts.Height)
+	off := 0
+	for y := 0; y < opts.Depth; y++ {
+		for x := 0; x < opts.Width; x++ {
+			if off == len(data) {
+				return &DecodeError{Offset: off, X: x, Y: y, Span: -1,
+					Err: fmt.Errorf("map data ends after %d of %dx%d columns",
+						x+y*opts.Width, opts.Width, opts.Depth)}
 			}
-			z = 0
-			for {
-				if len(v) < 4 {
-					return errors.New("insufficient data")
-				}
-				number4ByteChunks := int(v[0])
-				topColorStart := int(v[1])
-				topColorEnd := int(v[2]) // inclusive
-
-				for i := z; i < topColorStart; i++ {
-					if err := setGeom(x, y, i, 0); err != nil {
-						return err
-					}
+			var err error
+			off, err = col.decode(data, off, opts.Strict)
+			if err != nil {
+				var de *DecodeError
+				if errors.As(err, &de) {
+					de.X, de.Y = x, y
 				}
+				return err
+			}
+			if world != nil {
+				col.store(world, x, y)
+			}
+		}
+	}
 
-				color := v[4:]
-				for z = topColorStart; z <= topColorEnd; z++ {
-					if len(color) < 4 {
-						return errors.New("insufficient color data")
-					}
-					c := binary.BigEndian.Uint32(color)
-					if err := setColor(x, y, z, c); err != nil {
-						return err
-					}
-					color = color[4:]
-				}
+	if off == len(data) {
+		return nil
+	}
+	// Trailing bytes that form another column mean the map is larger than
+	// requested rather than followed by junk.
+	if _, err := newColumn(opts.Height).decode(data, off, opts.Strict); err == nil {
+		return &DecodeError{Offset: off, X: -1, Y: -1, Span: -1,
+			Err: fmt.Errorf("map has more than %dx%d columns", opts.Width, opts.Depth)}
+	}
+	if opts.Strict {
+		return &DecodeError{Offset: off, X: -1, Y: -1, Span: -1,
+			Err: fmt.Errorf("%d trailing bytes after the last column", len(data)-off)}
+	}
+	return nil
+}
 
-				lenBottom := topColorEnd - topColorStart + 1
+// decode reads the spans of one column starting at data[off] and returns the
+// offset of the next column. Errors are *DecodeError without the column set.
+func (col *column) decode(data []byte, off int, strict bool) (int, error) {
+	for z := 0; z < len(col.solid); z++ {
+		col.solid[z] = true
+		col.color[z] = 0
+	}
+	z := 0
+	for span := 0; ; span++ {
+		spanOff := off
+		fail := func(format string, args ...any) error {
+			return &DecodeError{Offset: spanOff, X: -1, Y: -1, Span: span, Err: fmt.Errorf(format, args...)}
+		}
 
-				if number4ByteChunks == 0 {
-					v = v[4*(lenBottom+1):]
-					break
-				}
+		v := data[off:]
+		if len(v) < 4 {
+			return 0, fail("insufficient data")
+		}
+		number4ByteChunks := int(v[0])
+		topColorStart := int(v[1])
+		topColorEnd := int(v[2]) // inclusive
+
+		if strict && topColorStart >= len(col.solid) {
+			return 0, fail("top colors start at z %d, below the map height %d", topColorStart, len(col.solid))
+		}
+		if strict && topColorStart < z {
+			return 0, fail("top colors start at z %d, overlapping the previous span ending at %d", topColorStart, z)
+		}
+		for i := z; i < topColorStart; i++ {
+			if err := col.setGeom(i, false); err != nil {
+				return 0, fail("%w", err)
+			}
+		}
 
-				lenTop := (number4ByteChunks - 1) - lenBottom
+		color := v[4:]
+		for z = topColorStart; z <= topColorEnd; z++ {
+			if len(color) < 4 {
+				return 0, fail("insufficient color data")
+			}
+			c := binary.BigEndian.Uint32(color)
+			if err := col.setColor(z, c); err != nil {
+				return 0, fail("%w", err)
+			}
+			color = color[4:]
+		}
 
-				v = v[number4ByteChunks*4:]
+		lenBottom := topColorEnd - topColorStart + 1
 
-				if len(v) < 4 {
-					return errors.New("insufficient data for bottom color end")
-				}
-				bottomColorEnd := int(v[3])
-				bottomColorStart := bottomColorEnd - lenTop
-
-				for z = bottomColorStart; z < bottomColorEnd; z++ {
-					if len(color) < 4 {
-						return errors.New("insufficient color data")
-					}
-					c := binary.BigEndian.Uint32(color)
-					if err := setColor(x, y, z, c); err != nil {
-						return err
-					}
-					color = color[4:]
-				}
+		if number4ByteChunks == 0 {
+			if lenBottom < 0 {
+				return 0, fail("top color end %d before start %d", topColorEnd, topColorStart)
 			}
+			return off + 4*(lenBottom+1), nil
 		}
-	}
 
-	// if len(v) != length {
-	// 	return fmt.Errorf("length mismatch: %d != %d", len(v), length)
-	// }
+		lenTop := (number4ByteChunks - 1) - lenBottom
+		if strict && lenTop < 0 {
+			return 0, fail("span length %d too short for %d top colors", number4ByteChunks, lenBottom)
+		}
 
-	return nil
+		if len(v) < number4ByteChunks*4 {
+			return 0, fail("insufficient span data")
+		}
+		off += number4ByteChunks * 4
+		v = data[off:]
+
+		if len(v) < 4 {
+			return 0, fail("insufficient data for bottom color end")
+		}
+		bottomColorEnd := int(v[3])
+		bottomColorStart := bottomColorEnd - lenTop
+
+		if strict && bottomColorStart < z {
+			return 0, fail("bottom colors start at z %d, overlapping the top colors ending at %d", bottomColorStart, z)
+		}
+		for z = bottomColorStart; z < bottomColorEnd; z++ {
+			if len(color) < 4 {
+				return 0, fail("insufficient color data")
+			}
+			c := binary.BigEndian.Uint32(color)
+			if err := col.setColor(z, c); err != nil {
+				return 0, fail("%w", err)
+			}
+			color = color[4:]
+		}
+	}
 }
@@ -0,0 +1,344 @@
+package maploader_test
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"sync"
+	"testing"
+
+	"github.com/pudelkoM/go-render/pkg/blockworld"
+	"github.com/pudelkoM/go-render/pkg/maploader"
+)
+
+// testWorld builds a world with hills, caves and floating platforms, so
+// that columns need one to three spans.
+func testWorld(sx, sy, sz int) *blockworld.Blockworld {
+	world := blockworld.NewBlockworld()
+	world.SetSize(sx, sy, sz)
+	for x := 0; x < sx; x++ {
+		for y := 0; y < sy; y++ {
+			set := func(z int) {
+				world.Set(x, y, z, blockworld.Block{Color: color.NRGBA{
+					R: uint8(x), G: uint8(y), B: uint8(z * 4), A: uint8(x + y + z),
+				}})
+			}
+			h := 1 + (x*7+y*13)%(sz*5/8)
+			for z := 0; z < h; z++ {
+				if (x+y)%9 == 0 && z >= 3 && z < 6 && h > 10 {
+					continue // cave
+				}
+				set(z)
+			}
+			if x%16 < 4 && y%16 < 4 {
+				for z := sz * 3 / 4; z < sz*3/4+3; z++ {
+					set(z)
+				}
+			}
+		}
+	}
+	return world
+}
+
+// exposed reports whether the block at (x, y, z) has an air neighbour or
+// is at the top of the map.
+func exposed(world *blockworld.Blockworld, x, y, z int) bool {
+	_, _, sz := world.Size()
+	if z == sz-1 {
+		return true
+	}
+	for _, d := range [6][3]int{{-1, 0, 0}, {1, 0, 0}, {0, -1, 0}, {0, 1, 0}, {0, 0, -1}, {0, 0, 1}} {
+		if b, ok := world.GetRaw(x+d[0], y+d[1], z+d[2]); b != nil && !ok {
+			return true
+		}
+	}
+	return false
+}
+
+func TestVXLRoundTrip(t *testing.T) {
+	world := testWorld(512, 512, 64)
+	data, err := maploader.EncodeVXL(world)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := blockworld.NewBlockworld()
+	if err := maploader.DecodeVXL(data, loaded); err != nil {
+		t.Fatal(err)
+	}
+
+	for x := 0; x < 512; x++ {
+		for y := 0; y < 512; y++ {
+			for z := 0; z < 64; z++ {
+				want, wantOk := world.GetRaw(x, y, z)
+				got, gotOk := loaded.GetRaw(x, y, z)
+				if wantOk != gotOk {
+					t.Fatalf("block (%d, %d, %d): set = %v, want %v", x, y, z, gotOk, wantOk)
+				}
+				if wantOk && exposed(world, x, y, z) && got.Color != want.Color {
+					t.Fatalf("block (%d, %d, %d): color = %v, want %v", x, y, z, got.Color, want.Color)
+				}
+			}
+		}
+	}
+
+	again, err := maploader.EncodeVXL(loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again, data) {
+		t.Errorf("re-encoded map differs: %d bytes, want %d", len(again), len(data))
+	}
+}
+
+func TestEncodeVXLHoleAtBottom(t *testing.T) {
+	world := testWorld(512, 512, 64)
+	// Set always marks blocks as set, so clear (7, 3, 0) through the slice.
+	world.Blocks()[7+3*512].Flags &^= blockworld.FlagSet
+
+	if _, err := maploader.EncodeVXL(world); err == nil {
+		t.Error("EncodeVXL() succeeded for a column without a bottom block")
+	}
+}
+
+func TestDecodeVXLConcurrent(t *testing.T) {
+	data, err := maploader.EncodeVXL(testWorld(512, 512, 64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := blockworld.NewBlockworld()
+	if err := maploader.DecodeVXL(data, want); err != nil {
+		t.Fatal(err)
+	}
+
+	const loaders = 3
+	worlds := make([]*blockworld.Blockworld, loaders)
+	errs := make([]error, loaders)
+	wg := sync.WaitGroup{}
+	wg.Add(loaders)
+	for i := 0; i < loaders; i++ {
+		go func(i int) {
+			defer wg.Done()
+			worlds[i] = blockworld.NewBlockworld()
+			errs[i] = maploader.DecodeVXL(data, worlds[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for i, world := range worlds {
+		if errs[i] != nil {
+			t.Fatalf("loader %d: %v", i, errs[i])
+		}
+		for j, b := range world.Blocks() {
+			if b != want.Blocks()[j] {
+				t.Fatalf("loader %d: block %d = %+v, want %+v", i, j, b, want.Blocks()[j])
+			}
+		}
+	}
+}
+
+func BenchmarkDecodeVXL(b *testing.B) {
+	data, err := maploader.EncodeVXL(testWorld(512, 512, 64))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		world := blockworld.NewBlockworld()
+		if err := maploader.DecodeVXL(data, world); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDecodeVXLWithOptions(t *testing.T) {
+	world := testWorld(48, 32, 128)
+	data, err := maploader.EncodeVXL(world)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, opts := range []maploader.Options{
+		{Width: 48, Depth: 32, Height: 128},
+		{Width: 48, Depth: 0, Height: 128},
+		{Width: 0, Depth: 32, Height: 128},
+	} {
+		loaded := blockworld.NewBlockworld()
+		if err := maploader.DecodeVXLWithOptions(data, loaded, opts); err != nil {
+			t.Fatalf("%+v: %v", opts, err)
+		}
+		if x, y, z := loaded.Size(); x != 48 || y != 32 || z != 128 {
+			t.Fatalf("%+v: size = %dx%dx%d, want 48x32x128", opts, x, y, z)
+		}
+		for i, b := range world.Blocks() {
+			if b.IsSet() != loaded.Blocks()[i].IsSet() {
+				t.Fatalf("%+v: block %d: set = %v, want %v", opts, i, loaded.Blocks()[i].IsSet(), b.IsSet())
+			}
+		}
+	}
+}
+
+func TestDecodeVXLDetectSquare(t *testing.T) {
+	data, err := maploader.EncodeVXL(testWorld(64, 64, 64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := blockworld.NewBlockworld()
+	if err := maploader.DecodeVXLWithOptions(data, loaded, maploader.Options{Height: 64}); err != nil {
+		t.Fatal(err)
+	}
+	if x, y, z := loaded.Size(); x != 64 || y != 64 || z != 64 {
+		t.Errorf("size = %dx%dx%d, want 64x64x64", x, y, z)
+	}
+}
+
+func TestDecodeVXLSizeMismatch(t *testing.T) {
+	data, err := maploader.EncodeVXL(testWorld(64, 64, 128))
+	if err != nil {
+		t.Fatal(err)
+	}
+	notSquare, err := maploader.EncodeVXL(testWorld(64, 63, 128))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		opts maploader.Options
+	}{
+		{name: "too few columns", data: data, opts: maploader.Options{Width: 32, Depth: 32, Height: 128}},
+		{name: "too many columns", data: data, opts: maploader.Options{Width: 128, Depth: 64, Height: 128}},
+		{name: "too low", data: data, opts: maploader.Options{Width: 64, Depth: 64, Height: 64}},
+		{name: "not square", data: notSquare, opts: maploader.Options{Height: 128}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := maploader.DecodeVXLWithOptions(tt.data, blockworld.NewBlockworld(), tt.opts)
+			if err == nil {
+				t.Error("DecodeVXLWithOptions() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestDecodeVXLStrict(t *testing.T) {
+	valid, err := maploader.EncodeVXL(testWorld(8, 8, 64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := maploader.Options{Width: 8, Depth: 8, Height: 64}
+
+	tests := []struct {
+		name string
+		data []byte
+		opts maploader.Options
+		want maploader.DecodeError // Err is not compared, nor Offset if negative
+	}{
+		{
+			name: "trailing garbage",
+			data: append(bytes.Clone(valid), 1, 2, 3),
+			opts: opts,
+			want: maploader.DecodeError{Offset: len(valid), X: -1, Y: -1, Span: -1},
+		},
+		{
+			name: "truncated",
+			data: valid[:len(valid)-2],
+			opts: opts,
+			want: maploader.DecodeError{Offset: -1, X: 7, Y: 7, Span: 0},
+		},
+		{
+			// Too small for the size, which must not be allocated.
+			name: "larger size",
+			data: valid,
+			opts: maploader.Options{Width: 1 << 16, Depth: 1 << 16, Height: 64},
+			want: maploader.DecodeError{Offset: len(valid), X: 64, Y: 0, Span: -1},
+		},
+		{
+			// The second span starts its top colors at z 5, above the air
+			// that starts at z 12.
+			name: "overlapping spans",
+			data: []byte{
+				2, 10, 10, 0, 1, 2, 3, 4,
+				0, 5, 5, 12, 1, 2, 3, 4,
+			},
+			opts: maploader.Options{Width: 1, Depth: 1, Height: 64},
+			want: maploader.DecodeError{Offset: 8, X: 0, Y: 0, Span: 1},
+		},
+		{
+			// The span declares 2 chunks, too few for its 3 top colors.
+			name: "short span",
+			data: []byte{
+				2, 10, 12, 0, 1, 2, 3, 4, 1, 2, 3, 4, 1, 2, 3, 4,
+				0, 20, 20, 30, 1, 2, 3, 4,
+			},
+			opts: maploader.Options{Width: 1, Depth: 1, Height: 64},
+			want: maploader.DecodeError{Offset: 0, X: 0, Y: 0, Span: 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Strict = true
+			err := maploader.DecodeVXLWithOptions(tt.data, blockworld.NewBlockworld(), tt.opts)
+			var de *maploader.DecodeError
+			if !errors.As(err, &de) {
+				t.Fatalf("DecodeVXLWithOptions() = %v, want a *DecodeError", err)
+			}
+			if (tt.want.Offset >= 0 && de.Offset != tt.want.Offset) ||
+				de.X != tt.want.X || de.Y != tt.want.Y || de.Span != tt.want.Span {
+				t.Errorf("DecodeVXLWithOptions() = %+v, want %+v", *de, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeVXLLenient(t *testing.T) {
+	valid, err := maploader.EncodeVXL(testWorld(8, 8, 64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := maploader.Options{Width: 8, Depth: 8, Height: 64}
+	if err := maploader.DecodeVXLWithOptions(append(valid, 1, 2, 3), blockworld.NewBlockworld(), opts); err != nil {
+		t.Errorf("trailing garbage: %v", err)
+	}
+	overlapping := []byte{
+		2, 10, 10, 0, 1, 2, 3, 4,
+		0, 5, 5, 12, 1, 2, 3, 4,
+	}
+	opts = maploader.Options{Width: 1, Depth: 1, Height: 64}
+	if err := maploader.DecodeVXLWithOptions(overlapping, blockworld.NewBlockworld(), opts); err != nil {
+		t.Errorf("overlapping spans: %v", err)
+	}
+}
+
+func FuzzDecodeVXL(f *testing.F) {
+	for _, size := range [][3]int{{1, 1, 64}, {2, 2, 64}, {4, 4, 64}, {4, 2, 16}} {
+		data, err := maploader.EncodeVXL(testWorld(size[0], size[1], size[2]))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data, uint8(size[0]), uint8(size[1]), uint8(size[2]), false)
+		f.Add(data, uint8(0), uint8(0), uint8(size[2]), true)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte, width, depth, height uint8, strict bool) {
+		opts := maploader.Options{
+			Width:  int(width % 8),
+			Depth:  int(depth % 8),
+			Height: int(height),
+			Strict: strict,
+		}
+		world := blockworld.NewBlockworld()
+		if err := maploader.DecodeVXLWithOptions(data, world, opts); err != nil {
+			return
+		}
+		if opts.Strict {
+			// Anything strict mode accepts must survive a round trip.
+			if _, err := maploader.EncodeVXL(world); err != nil {
+				t.Errorf("EncodeVXL() of a strictly decoded map: %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package maploader
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pudelkoM/go-render/pkg/blockworld"
+)
+
+// SaveMap writes world to path as an Ace of Spades VXL map.
+func SaveMap(path string, world *blockworld.Blockworld) error {
+	data, err := EncodeVXL(world)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// EncodeVXL serializes world into the Ace of Spades VXL format, the inverse
+// of DecodeVXL. Only surface blocks, i.e. blocks next to air, get their color
+// stored; hidden blocks are implied by the span encoding. Every column must
+// have a block at the bottom, since the format cannot express a hole there.
+// Maps with a size other than DefaultOptions need the matching Options to be
+// decoded again.
+func EncodeVXL(world *blockworld.Blockworld) ([]byte, error) {
+	sx, sy, sz := world.Size()
+	if sz > 256 {
+		return nil, fmt.Errorf("world height %d exceeds the VXL limit of 256", sz)
+	}
+
+	var out []byte
+	for y := 0; y < sy; y++ {
+		for x := 0; x < sx; x++ {
+			var err error
+			out, err = appendColumn(out, world, x, y)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return out, nil
+}
+
+// appendColumn appends the spans of column (x, y) to out. Like loadMap it
+// works in VXL coordinates, where z = 0 is the top of the map.
+func appendColumn(out []byte, world *blockworld.Blockworld, x, y int) ([]byte, error) {
+	sx, sy, sz := world.Size()
+	solid := func(z int) bool {
+		_, ok := world.GetRaw(x, y, sz-1-z)
+		return ok
+	}
+	surface := func(z int) bool {
+		if !solid(z) {
+			return false
+		}
+		if z == 0 || !solid(z-1) || (z+1 < sz && !solid(z+1)) {
+			return true
+		}
+		// Blocks outside the map count as solid.
+		for _, d := range [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+			nx, ny := x+d[0], y+d[1]
+			if nx < 0 || nx >= sx || ny < 0 || ny >= sy {
+				continue
+			}
+			if _, ok := world.GetRaw(nx, ny, sz-1-z); !ok {
+				return true
+			}
+		}
+		return false
+	}
+	appendColors := func(out []byte, from, to int) []byte {
+		for z := from; z < to; z++ {
+			b, _ := world.GetRaw(x, y, sz-1-z)
+			// Inverse of the alpha shift in LoadMap.
+			out = append(out, b.Color.B, b.Color.G, b.Color.R, b.Color.A-128)
+		}
+		return out
+	}
+
+	if !solid(sz - 1) {
+		return nil, fmt.Errorf("column (%d, %d) has no block at the bottom", x, y)
+	}
+
+	z := 0
+	airStart := 0
+	for {
+		for !solid(z) {
+			z++
+		}
+		topStart := z
+		end := z
+		for end < sz && solid(end) {
+			end++
+		}
+
+		if end == sz {
+			// Last span: everything below the top colors is solid, so the
+			// top colors have to reach down to the lowest surface block.
+			topEnd := topStart
+			for k := topStart; k < sz; k++ {
+				if surface(k) {
+					topEnd = k
+				}
+			}
+			out = append(out, 0, byte(topStart), byte(topEnd), byte(airStart))
+			return appendColors(out, topStart, topEnd+1), nil
+		}
+
+		topEnd := topStart
+		for topEnd+1 < end && surface(topEnd+1) {
+			topEnd++
+		}
+		bottomStart := end
+		for k := topEnd + 1; k < end; k++ {
+			if surface(k) {
+				bottomStart = k
+				break
+			}
+		}
+
+		n := 1 + (topEnd - topStart + 1) + (end - bottomStart)
+		out = append(out, byte(n), byte(topStart), byte(topEnd), byte(airStart))
+		out = appendColors(out, topStart, topEnd+1)
+		out = appendColors(out, bottomStart, end)
+		airStart = end
+		z = end
+	}
+}
@@ -0,0 +1,140 @@
+package maploader
+
+import (
+	"cmp"
+	"image/color"
+	"slices"
+)
+
+// Quantizer reduces a color histogram to a palette of at most n colors.
+type Quantizer interface {
+	Quantize(histogram map[color.NRGBA]int, n int) []color.NRGBA
+}
+
+// PopularityQuantizer keeps the n most frequent colors. It is fast and
+// exact for worlds with few colors, but drops rare colors entirely.
+type PopularityQuantizer struct{}
+
+func (PopularityQuantizer) Quantize(histogram map[color.NRGBA]int, n int) []color.NRGBA {
+	colors := sortedColors(histogram)
+	slices.SortStableFunc(colors, func(a, b color.NRGBA) int {
+		return cmp.Compare(histogram[b], histogram[a])
+	})
+	return colors[:min(n, len(colors))]
+}
+
+// MedianCutQuantizer repeatedly splits the box of colors with the widest
+// channel range at its median and averages each box into one color.
+type MedianCutQuantizer struct{}
+
+func (MedianCutQuantizer) Quantize(histogram map[color.NRGBA]int, n int) []color.NRGBA {
+	colors := sortedColors(histogram)
+	if len(colors) <= n {
+		return colors
+	}
+
+	boxes := [][]color.NRGBA{colors}
+	for len(boxes) < n {
+		// Split the box with the widest range in any channel.
+		best, bestCh, bestRange := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			for ch := 0; ch < 4; ch++ {
+				lo, hi := uint8(255), uint8(0)
+				for _, c := range box {
+					lo = min(lo, channel(c, ch))
+					hi = max(hi, channel(c, ch))
+				}
+				if int(hi-lo) > bestRange || best < 0 {
+					best, bestCh, bestRange = i, ch, int(hi-lo)
+				}
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		box := boxes[best]
+		slices.SortStableFunc(box, func(a, b color.NRGBA) int {
+			return cmp.Compare(channel(a, bestCh), channel(b, bestCh))
+		})
+		// Cut where half of the blocks, not of the colors, are on each side.
+		total := 0
+		for _, c := range box {
+			total += histogram[c]
+		}
+		cut, seen := 1, 0
+		for i, c := range box[:len(box)-1] {
+			seen += histogram[c]
+			cut = i + 1
+			if 2*seen >= total {
+				break
+			}
+		}
+		boxes[best] = box[:cut]
+		boxes = append(boxes, box[cut:])
+	}
+
+	palette := make([]color.NRGBA, 0, len(boxes))
+	for _, box := range boxes {
+		var sum [4]int
+		total := 0
+		for _, c := range box {
+			w := histogram[c]
+			for ch := 0; ch < 4; ch++ {
+				sum[ch] += int(channel(c, ch)) * w
+			}
+			total += w
+		}
+		palette = append(palette, color.NRGBA{
+			R: uint8((sum[0] + total/2) / total),
+			G: uint8((sum[1] + total/2) / total),
+			B: uint8((sum[2] + total/2) / total),
+			A: uint8((sum[3] + total/2) / total),
+		})
+	}
+	return palette
+}
+
+func channel(c color.NRGBA, ch int) uint8 {
+	switch ch {
+	case 0:
+		return c.R
+	case 1:
+		return c.G
+	case 2:
+		return c.B
+	default:
+		return c.A
+	}
+}
+
+// sortedColors returns the colors of histogram in a deterministic order.
+func sortedColors(histogram map[color.NRGBA]int) []color.NRGBA {
+	colors := make([]color.NRGBA, 0, len(histogram))
+	for c := range histogram {
+		colors = append(colors, c)
+	}
+	slices.SortFunc(colors, func(a, b color.NRGBA) int {
+		return cmp.Or(cmp.Compare(a.R, b.R), cmp.Compare(a.G, b.G), cmp.Compare(a.B, b.B), cmp.Compare(a.A, b.A))
+	})
+	return colors
+}
+
+// nearestColor returns the index of the palette entry closest to c.
+func nearestColor(palette []color.NRGBA, c color.NRGBA) int {
+	best, bestDist := 0, -1
+	for i, p := range palette {
+		dist := 0
+		for ch := 0; ch < 4; ch++ {
+			d := int(channel(p, ch)) - int(channel(c, ch))
+			dist += d * d
+		}
+		if bestDist < 0 || dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	return best
+}
@@ -0,0 +1,405 @@
+package maploader
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pudelkoM/go-render/pkg/blockworld"
+)
+
+// MagicaVoxel .vox support. A file is a tree of chunks below MAIN: SIZE and
+// XYZI pairs hold the models, RGBA the palette and the optional nTRN, nGRP
+// and nSHP chunks a scene graph that places the models. Like Blockworld,
+// the format uses z as the up axis.
+
+// Limits of .vox scenes, so corrupt files cannot make DecodeVox run out of
+// memory: the edges of the bounding box, the number of blocks of the world
+// it is sized to and the number of voxels the scene graph places.
+const (
+	voxMaxExtent = 2048
+	voxMaxVolume = 1 << 26 // 512 MB of dense blocks
+	voxMaxVoxels = 1 << 22
+)
+
+// VoxError describes where decoding a .vox file failed.
+type VoxError struct {
+	Offset int // byte offset of the chunk at fault
+	Err    error
+}
+
+func (e *VoxError) Error() string {
+	return fmt.Sprintf("vox: offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *VoxError) Unwrap() error {
+	return e.Err
+}
+
+// voxModel is one SIZE/XYZI pair.
+type voxModel struct {
+	offset int // of its SIZE chunk in the file
+	size   [3]int
+	voxels [][4]uint8 // x, y, z, color index
+}
+
+// voxNode is a scene graph node. Transform nodes have one child, groups any
+// number and shapes none.
+type voxNode struct {
+	kind     string
+	offset   int // of its chunk in the file
+	children []int
+	models   []int
+	xf       voxTransform
+}
+
+// voxTransform maps a point p to rot*p + trans.
+type voxTransform struct {
+	rot   [3][3]int
+	trans [3]int
+}
+
+var voxIdentity = voxTransform{rot: [3][3]int{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}}
+
+func (t voxTransform) apply(p [3]int) [3]int {
+	var out [3]int
+	for i := 0; i < 3; i++ {
+		out[i] = t.rot[i][0]*p[0] + t.rot[i][1]*p[1] + t.rot[i][2]*p[2] + t.trans[i]
+	}
+	return out
+}
+
+// then returns the transform that applies c first and t second.
+func (t voxTransform) then(c voxTransform) voxTransform {
+	var out voxTransform
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			out.rot[i][j] = t.rot[i][0]*c.rot[0][j] + t.rot[i][1]*c.rot[1][j] + t.rot[i][2]*c.rot[2][j]
+		}
+	}
+	out.trans = t.apply(c.trans)
+	return out
+}
+
+// defaultVoxPalette is used by files without an RGBA chunk: a 6x6x6 color
+// cube without black followed by red, green, blue and gray ramps.
+var defaultVoxPalette = func() [256]color.NRGBA {
+	var p [256]color.NRGBA
+	i := 1
+	for r := 5; r >= 0; r-- {
+		for g := 5; g >= 0; g-- {
+			for b := 5; b >= 0; b-- {
+				if r == 0 && g == 0 && b == 0 {
+					continue
+				}
+				p[i] = color.NRGBA{R: uint8(r * 0x33), G: uint8(g * 0x33), B: uint8(b * 0x33), A: 255}
+				i++
+			}
+		}
+	}
+	ramp := []uint8{0xee, 0xdd, 0xbb, 0xaa, 0x88, 0x77, 0x55, 0x44, 0x22, 0x11}
+	for ch := 0; ch < 4; ch++ {
+		for _, v := range ramp {
+			c := color.NRGBA{A: 255}
+			switch ch {
+			case 0:
+				c.R = v
+			case 1:
+				c.G = v
+			case 2:
+				c.B = v
+			default:
+				c.R, c.G, c.B = v, v, v
+			}
+			p[i] = c
+			i++
+		}
+	}
+	return p
+}()
+
+// LoadVox fills world from a MagicaVoxel .vox file.
+func LoadVox(path string, world *blockworld.Blockworld) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return DecodeVox(data, world)
+}
+
+// DecodeVox fills world from the MagicaVoxel .vox file in data. Models are
+// placed by the scene graph if the file has one, or at the origin
+// otherwise. The world is resized to the bounding box of all voxels and its
+// distance field is computed. Scenes beyond 2048 blocks across, 2^26 blocks
+// in total or 2^22 voxels are rejected with a *VoxError, as are scene
+// graphs that reach a node twice.
+func DecodeVox(data []byte, world *blockworld.Blockworld) error {
+	if len(data) < 8 || string(data[:4]) != "VOX " {
+		return errors.New("vox: missing VOX header")
+	}
+	r := voxReader{data: data[8:]}
+	id, content, children := r.chunk()
+	// The children of MAIN start after its header and content.
+	childrenOff := 8 + 12 + len(content)
+	if r.err != nil {
+		return r.err
+	}
+	if id != "MAIN" {
+		return fmt.Errorf("vox: first chunk is %q, want MAIN", id)
+	}
+
+	var models []voxModel
+	palette := defaultVoxPalette
+	nodes := map[int]*voxNode{}
+
+	cr := voxReader{data: children}
+	for len(cr.data) > 0 && cr.err == nil {
+		off := childrenOff + len(children) - len(cr.data)
+		id, content, _ := cr.chunk()
+		c := voxReader{data: content}
+		switch id {
+		case "SIZE":
+			models = append(models, voxModel{offset: off, size: [3]int{c.int(), c.int(), c.int()}})
+		case "XYZI":
+			if len(models) == 0 || models[len(models)-1].voxels != nil {
+				return errors.New("vox: XYZI chunk without SIZE")
+			}
+			n := c.int()
+			if n < 0 || n > len(c.data)/4 {
+				return fmt.Errorf("vox: invalid voxel count %d", n)
+			}
+			voxels := make([][4]uint8, n)
+			for i := range voxels {
+				copy(voxels[i][:], c.bytes(4))
+			}
+			models[len(models)-1].voxels = voxels
+		case "RGBA":
+			for i := 1; i < 256; i++ {
+				b := c.bytes(4)
+				if b == nil {
+					break
+				}
+				palette[i] = color.NRGBA{R: b[0], G: b[1], B: b[2], A: b[3]}
+			}
+		case "nTRN":
+			n := &voxNode{kind: id, offset: off, xf: voxIdentity}
+			nodeID := c.int()
+			c.dict()
+			n.children = []int{c.int()}
+			c.int() // reserved
+			c.int() // layer
+			if frames := c.int(); frames > 0 {
+				// Only the first animation frame is used.
+				n.xf = parseVoxFrame(c.dict())
+			}
+			nodes[nodeID] = n
+		case "nGRP":
+			n := &voxNode{kind: id, offset: off}
+			nodeID := c.int()
+			c.dict()
+			count := c.int()
+			for i := 0; i < count && c.err == nil; i++ {
+				n.children = append(n.children, c.int())
+			}
+			nodes[nodeID] = n
+		case "nSHP":
+			n := &voxNode{kind: id, offset: off}
+			nodeID := c.int()
+			c.dict()
+			count := c.int()
+			for i := 0; i < count && c.err == nil; i++ {
+				n.models = append(n.models, c.int())
+				c.dict()
+			}
+			nodes[nodeID] = n
+		}
+		if c.err != nil {
+			return fmt.Errorf("vox: %s chunk: %w", id, c.err)
+		}
+	}
+	if cr.err != nil {
+		return cr.err
+	}
+
+	type placed struct {
+		pos   [3]int
+		color uint8
+	}
+	var voxels []placed
+	var lo, hi [3]int // bounding box of voxels
+	// place adds the voxels of model m. Errors point at offset off, the
+	// chunk that places the model.
+	place := func(m int, xf voxTransform, center bool, off int) error {
+		if m < 0 || m >= len(models) {
+			return fmt.Errorf("vox: shape references missing model %d", m)
+		}
+		model := models[m]
+		if len(voxels)+len(model.voxels) > voxMaxVoxels {
+			return &VoxError{Offset: off, Err: fmt.Errorf("the scene places more than %d voxels", voxMaxVoxels)}
+		}
+		for _, v := range model.voxels {
+			p := [3]int{int(v[0]), int(v[1]), int(v[2])}
+			if center {
+				// The scene graph positions the center of a model.
+				for i := range p {
+					p[i] -= model.size[i] / 2
+				}
+			}
+			p = xf.apply(p)
+			if len(voxels) == 0 {
+				lo, hi = p, p
+			}
+			for i := range p {
+				lo[i], hi[i] = min(lo[i], p[i]), max(hi[i], p[i])
+				if hi[i]-lo[i] >= voxMaxExtent {
+					return &VoxError{Offset: off, Err: fmt.Errorf("model %d is placed more than %d blocks from the others", m, voxMaxExtent)}
+				}
+			}
+			voxels = append(voxels, placed{pos: p, color: v[3]})
+		}
+		if len(voxels) > 0 {
+			if v := (hi[0] - lo[0] + 1) * (hi[1] - lo[1] + 1) * (hi[2] - lo[2] + 1); v > voxMaxVolume {
+				return &VoxError{Offset: off, Err: fmt.Errorf("model %d grows the scene to %d blocks, more than %d", m, v, voxMaxVolume)}
+			}
+		}
+		return nil
+	}
+
+	if _, ok := nodes[0]; ok {
+		// Every node is placed once: cycles would never end, and nodes
+		// shared by several parents could multiply the voxels of a small
+		// file.
+		visited := map[int]bool{}
+		var walk func(id int, xf voxTransform) error
+		walk = func(id int, xf voxTransform) error {
+			n, ok := nodes[id]
+			if !ok {
+				return fmt.Errorf("vox: missing scene node %d", id)
+			}
+			if visited[id] {
+				return &VoxError{Offset: n.offset, Err: fmt.Errorf("scene node %d is reached twice", id)}
+			}
+			visited[id] = true
+			if n.kind == "nTRN" {
+				xf = xf.then(n.xf)
+			}
+			for _, m := range n.models {
+				if err := place(m, xf, true, n.offset); err != nil {
+					return err
+				}
+			}
+			for _, c := range n.children {
+				if err := walk(c, xf); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if err := walk(0, voxIdentity); err != nil {
+			return err
+		}
+	} else {
+		for m := range models {
+			if err := place(m, voxIdentity, false, models[m].offset); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(voxels) == 0 {
+		world.SetSize(0, 0, 0)
+		return nil
+	}
+	world.SetSize(hi[0]-lo[0]+1, hi[1]-lo[1]+1, hi[2]-lo[2]+1)
+	for _, v := range voxels {
+		world.Set(v.pos[0]-lo[0], v.pos[1]-lo[1], v.pos[2]-lo[2], blockworld.Block{Color: palette[v.color]})
+	}
+	world.ComputeDistances()
+	return nil
+}
+
+// parseVoxFrame reads the translation "_t" and the packed rotation "_r" of
+// an nTRN frame.
+func parseVoxFrame(attrs map[string]string) voxTransform {
+	xf := voxIdentity
+	if t, ok := attrs["_t"]; ok {
+		for i, f := range strings.Fields(t) {
+			if i < 3 {
+				xf.trans[i], _ = strconv.Atoi(f)
+			}
+		}
+	}
+	if s, ok := attrs["_r"]; ok {
+		r, err := strconv.Atoi(s)
+		if err == nil {
+			// Bits 0-1 and 2-3 hold the column of the non-zero entry in
+			// rows 0 and 1, bits 4-6 the signs of rows 0 to 2.
+			c0, c1 := r&3, (r>>2)&3
+			c2 := 3 - c0 - c1
+			if c0 < 3 && c1 < 3 && c0 != c1 {
+				xf.rot = [3][3]int{}
+				for row, col := range [3]int{c0, c1, c2} {
+					xf.rot[row][col] = 1
+					if r&(1<<(4+row)) != 0 {
+						xf.rot[row][col] = -1
+					}
+				}
+			}
+		}
+	}
+	return xf
+}
+
+// voxReader reads little-endian values from a chunk. After the first error
+// all reads return zero values and err is set.
+type voxReader struct {
+	data []byte
+	err  error
+}
+
+func (r *voxReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data) {
+		r.err = errors.New("vox: unexpected end of data")
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *voxReader) int() int {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return int(int32(binary.LittleEndian.Uint32(b)))
+}
+
+func (r *voxReader) string() string {
+	return string(r.bytes(r.int()))
+}
+
+func (r *voxReader) dict() map[string]string {
+	n := r.int()
+	d := map[string]string{}
+	for i := 0; i < n && r.err == nil; i++ {
+		k := r.string()
+		d[k] = r.string()
+	}
+	return d
+}
+
+// chunk reads a chunk header and returns its id, content and children.
+func (r *voxReader) chunk() (string, []byte, []byte) {
+	id := string(r.bytes(4))
+	contentSize := r.int()
+	childrenSize := r.int()
+	return id, r.bytes(contentSize), r.bytes(childrenSize)
+}
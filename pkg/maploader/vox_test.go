@@ -0,0 +1,340 @@
+package maploader_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"image/color"
+	"testing"
+
+	"github.com/pudelkoM/go-render/pkg/blockworld"
+	"github.com/pudelkoM/go-render/pkg/maploader"
+)
+
+// voxChunk encodes a .vox chunk.
+func voxChunk(id string, content []byte, children ...[]byte) []byte {
+	var kids []byte
+	for _, c := range children {
+		kids = append(kids, c...)
+	}
+	out := []byte(id)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(content)))
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(kids)))
+	out = append(out, content...)
+	return append(out, kids...)
+}
+
+func voxInts(vs ...int) []byte {
+	var out []byte
+	for _, v := range vs {
+		out = binary.LittleEndian.AppendUint32(out, uint32(int32(v)))
+	}
+	return out
+}
+
+// voxDict encodes a DICT from key, value pairs.
+func voxDict(kv ...string) []byte {
+	out := voxInts(len(kv) / 2)
+	for _, s := range kv {
+		out = append(out, voxInts(len(s))...)
+		out = append(out, s...)
+	}
+	return out
+}
+
+func voxFile(chunks ...[]byte) []byte {
+	return append([]byte("VOX \x96\x00\x00\x00"), voxChunk("MAIN", nil, chunks...)...)
+}
+
+// voxModelChunks encodes a model of size x, y, z from x, y, z, color
+// index quadruples.
+func voxModelChunks(x, y, z int, voxels ...uint8) [][]byte {
+	return [][]byte{
+		voxChunk("SIZE", voxInts(x, y, z)),
+		voxChunk("XYZI", append(voxInts(len(voxels)/4), voxels...)),
+	}
+}
+
+// voxTRN encodes a transform node with one frame holding the attributes kv.
+func voxTRN(id, child int, kv ...string) []byte {
+	content := append(voxInts(id), voxDict()...)
+	content = append(content, voxInts(child, -1, 0, 1)...)
+	return voxChunk("nTRN", append(content, voxDict(kv...)...))
+}
+
+func voxGRP(id int, children ...int) []byte {
+	content := append(voxInts(id), voxDict()...)
+	content = append(content, voxInts(len(children))...)
+	return voxChunk("nGRP", append(content, voxInts(children...)...))
+}
+
+func voxSHP(id, model int) []byte {
+	content := append(voxInts(id), voxDict()...)
+	content = append(content, voxInts(1, model)...)
+	return voxChunk("nSHP", append(content, voxDict()...))
+}
+
+func blockColor(t *testing.T, world *blockworld.Blockworld, x, y, z int) color.NRGBA {
+	t.Helper()
+	b, ok := world.GetRaw(x, y, z)
+	if !ok {
+		t.Fatalf("block (%d, %d, %d) is not set", x, y, z)
+	}
+	return b.Color
+}
+
+func TestDecodeVoxPalette(t *testing.T) {
+	palette := make([]byte, 256*4)
+	copy(palette, []byte{10, 20, 30, 255, 40, 50, 60, 128})
+
+	model := voxModelChunks(3, 2, 4, 0, 0, 0, 1, 2, 1, 3, 2)
+	world := blockworld.NewBlockworld()
+	if err := maploader.DecodeVox(voxFile(model[0], model[1], voxChunk("RGBA", palette)), world); err != nil {
+		t.Fatal(err)
+	}
+	if x, y, z := world.Size(); x != 3 || y != 2 || z != 4 {
+		t.Fatalf("size = %dx%dx%d, want 3x2x4", x, y, z)
+	}
+	if c := blockColor(t, world, 0, 0, 0); c != (color.NRGBA{10, 20, 30, 255}) {
+		t.Errorf("color index 1 = %v", c)
+	}
+	if c := blockColor(t, world, 2, 1, 3); c != (color.NRGBA{40, 50, 60, 128}) {
+		t.Errorf("color index 2 = %v", c)
+	}
+	if _, ok := world.GetRaw(1, 0, 0); ok {
+		t.Error("block (1, 0, 0) is set")
+	}
+}
+
+func TestDecodeVoxDefaultPalette(t *testing.T) {
+	model := voxModelChunks(1, 1, 1, 0, 0, 0, 1)
+	world := blockworld.NewBlockworld()
+	if err := maploader.DecodeVox(voxFile(model...), world); err != nil {
+		t.Fatal(err)
+	}
+	if c := blockColor(t, world, 0, 0, 0); c != (color.NRGBA{255, 255, 255, 255}) {
+		t.Errorf("default color index 1 = %v, want white", c)
+	}
+}
+
+func TestDecodeVoxSceneGraph(t *testing.T) {
+	// Two instances of a 2x1x1 model: one moved along x, one rotated by 90
+	// degrees around z (_r 17: row 0 takes -y, row 1 takes x).
+	model := voxModelChunks(2, 1, 1, 0, 0, 0, 1, 1, 0, 0, 2)
+	nodes := [][]byte{
+		voxTRN(0, 1),
+		voxGRP(1, 2, 4),
+		voxTRN(2, 3, "_t", "10 0 0"),
+		voxSHP(3, 0),
+		voxTRN(4, 5, "_r", "17"),
+		voxSHP(5, 0),
+	}
+
+	world := blockworld.NewBlockworld()
+	if err := maploader.DecodeVox(voxFile(append(model, nodes...)...), world); err != nil {
+		t.Fatal(err)
+	}
+
+	// Centered, the model covers x = -1..0. The translated copy covers
+	// x = 9..10, the rotated one y = -1..0 at x = 0.
+	if x, y, z := world.Size(); x != 11 || y != 2 || z != 1 {
+		t.Fatalf("size = %dx%dx%d, want 11x2x1", x, y, z)
+	}
+	first := color.NRGBA{255, 255, 255, 255}  // default color index 1
+	second := color.NRGBA{255, 255, 204, 255} // default color index 2
+	for _, tt := range []struct {
+		x, y int
+		want color.NRGBA
+	}{
+		{9, 1, first},
+		{10, 1, second},
+		{0, 0, first},
+		{0, 1, second},
+	} {
+		if c := blockColor(t, world, tt.x, tt.y, 0); c != tt.want {
+			t.Errorf("block (%d, %d, 0) = %v, want %v", tt.x, tt.y, c, tt.want)
+		}
+	}
+}
+
+func TestDecodeVoxInvalid(t *testing.T) {
+	model := voxModelChunks(1, 1, 1, 0, 0, 0, 1)
+	valid := voxFile(model...)
+	for name, data := range map[string][]byte{
+		"empty":     nil,
+		"no header": []byte("RIFF\x96\x00\x00\x00"),
+		"truncated": valid[:len(valid)-3],
+		"no SIZE":   voxFile(model[1]),
+	} {
+		if err := maploader.DecodeVox(data, blockworld.NewBlockworld()); err == nil {
+			t.Errorf("%s: DecodeVox() succeeded, want error", name)
+		}
+	}
+}
+
+func TestDecodeVoxBounds(t *testing.T) {
+	// Scenes that would need more memory than a file of a few hundred bytes
+	// deserves.
+	model := voxModelChunks(1, 1, 1, 0, 0, 0, 1)
+	twoCopies := func(t string) [][]byte {
+		return [][]byte{
+			voxTRN(0, 1),
+			voxGRP(1, 2, 4),
+			voxSHP(2, 0),
+			voxTRN(4, 5, "_t", t),
+			voxSHP(5, 0),
+		}
+	}
+	// Each group holds the next one twice, which places 2^20 copies.
+	var shared [][]byte
+	for i := 0; i < 20; i++ {
+		shared = append(shared, voxGRP(i, i+1, i+1))
+	}
+	shared = append(shared, voxSHP(20, 0))
+
+	for name, nodes := range map[string][][]byte{
+		"far apart":   twoCopies("100000 0 0"),
+		"large":       twoCopies("2000 2000 2000"),
+		"shared node": shared,
+	} {
+		err := maploader.DecodeVox(voxFile(append(model, nodes...)...), blockworld.NewBlockworld())
+		var ve *maploader.VoxError
+		if !errors.As(err, &ve) {
+			t.Errorf("%s: DecodeVox() = %v, want a *VoxError", name, err)
+			continue
+		}
+		if ve.Offset <= 0 {
+			t.Errorf("%s: error = %+v, want the offset of a chunk", name, ve)
+		}
+	}
+}
+
+// stripedWorld has a full floor and a few pillars in six colors.
+func stripedWorld(sx, sy, sz int) *blockworld.Blockworld {
+	colors := []color.NRGBA{
+		{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255},
+		{255, 255, 0, 200}, {0, 255, 255, 160}, {30, 30, 30, 128},
+	}
+	world := blockworld.NewBlockworld()
+	world.SetSize(sx, sy, sz)
+	for x := 0; x < sx; x++ {
+		for y := 0; y < sy; y++ {
+			world.Set(x, y, 0, blockworld.Block{Color: colors[(x/7)%len(colors)]})
+			if (x+y)%5 == 0 {
+				for z := 1; z < sz; z++ {
+					world.Set(x, y, z, blockworld.Block{Color: colors[z%len(colors)]})
+				}
+			}
+		}
+	}
+	return world
+}
+
+// opaque is c as EncodeVox stores it: darkened by its alpha, like VXL
+// shading darkens frames, and fully opaque.
+func opaque(c color.NRGBA) color.NRGBA {
+	r, g, b, _ := c.RGBA()
+	return color.NRGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), 255}
+}
+
+func TestVoxRoundTrip(t *testing.T) {
+	// 300 blocks along x need two models.
+	world := stripedWorld(300, 20, 8)
+	data, err := maploader.EncodeVox(world, maploader.VoxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := blockworld.NewBlockworld()
+	if err := maploader.DecodeVox(data, loaded); err != nil {
+		t.Fatal(err)
+	}
+	if x, y, z := loaded.Size(); x != 300 || y != 20 || z != 8 {
+		t.Fatalf("size = %dx%dx%d, want 300x20x8", x, y, z)
+	}
+	for i, b := range world.Blocks() {
+		if got := loaded.Blocks()[i]; got.IsSet() != b.IsSet() || (b.IsSet() && got.Color != opaque(b.Color)) {
+			t.Fatalf("block %d = %+v, want %+v", i, got, b)
+		}
+	}
+}
+
+func TestVoxRegion(t *testing.T) {
+	world := stripedWorld(40, 40, 8)
+	opts := maploader.VoxOptions{Min: blockworld.Point{X: 5, Y: 10, Z: 0}, Max: blockworld.Point{X: 15, Y: 13, Z: 4}}
+	data, err := maploader.EncodeVox(world, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := blockworld.NewBlockworld()
+	if err := maploader.DecodeVox(data, loaded); err != nil {
+		t.Fatal(err)
+	}
+	if x, y, z := loaded.Size(); x != 10 || y != 3 || z != 4 {
+		t.Fatalf("size = %dx%dx%d, want 10x3x4", x, y, z)
+	}
+	for x := 0; x < 10; x++ {
+		for y := 0; y < 3; y++ {
+			for z := 0; z < 4; z++ {
+				want, wantOk := world.GetRaw(x+5, y+10, z)
+				got, gotOk := loaded.GetRaw(x, y, z)
+				if wantOk != gotOk || (wantOk && got.Color != opaque(want.Color)) {
+					t.Fatalf("block (%d, %d, %d) = %+v, want %+v", x, y, z, got, want)
+				}
+			}
+		}
+	}
+
+	opts.Max.X = 41
+	if _, err := maploader.EncodeVox(world, opts); err == nil {
+		t.Error("EncodeVox() of a region outside the world succeeded")
+	}
+}
+
+func TestVoxQuantizers(t *testing.T) {
+	// A 32x32 gradient has 1024 colors, more than a palette holds.
+	world := blockworld.NewBlockworld()
+	world.SetSize(32, 32, 1)
+	for x := 0; x < 32; x++ {
+		for y := 0; y < 32; y++ {
+			world.Set(x, y, 0, blockworld.Block{Color: color.NRGBA{uint8(x * 8), uint8(y * 8), 100, 255}})
+		}
+	}
+
+	tests := []struct {
+		name      string
+		quantizer maploader.Quantizer
+		maxError  int // largest allowed per-channel error
+	}{
+		{name: "median cut", quantizer: maploader.MedianCutQuantizer{}, maxError: 16},
+		// All colors are equally frequent, so the ties keep the first 255
+		// in sorted order, with red up to 56, and red 248 ends up 192 off.
+		{name: "popularity", quantizer: maploader.PopularityQuantizer{}, maxError: 192},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := maploader.EncodeVox(world, maploader.VoxOptions{Quantizer: tt.quantizer})
+			if err != nil {
+				t.Fatal(err)
+			}
+			loaded := blockworld.NewBlockworld()
+			if err := maploader.DecodeVox(data, loaded); err != nil {
+				t.Fatal(err)
+			}
+			colors := map[color.NRGBA]bool{}
+			for i, b := range loaded.Blocks() {
+				want := world.Blocks()[i].Color
+				colors[b.Color] = true
+				for _, d := range []int{
+					int(b.Color.R) - int(want.R), int(b.Color.G) - int(want.G),
+					int(b.Color.B) - int(want.B), int(b.Color.A) - int(want.A),
+				} {
+					if d > tt.maxError || -d > tt.maxError {
+						t.Fatalf("block %d = %v, want about %v", i, b.Color, want)
+					}
+				}
+			}
+			if len(colors) > 255 {
+				t.Errorf("%d distinct colors, want at most 255", len(colors))
+			}
+		})
+	}
+}
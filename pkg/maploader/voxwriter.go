@@ -0,0 +1,174 @@
+package maploader
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"os"
+
+	"github.com/pudelkoM/go-render/pkg/blockworld"
+)
+
+// voxMaxSize is the largest model edge MagicaVoxel supports.
+const voxMaxSize = 256
+
+// VoxOptions controls EncodeVox.
+type VoxOptions struct {
+	// Min and Max bound the exported region, Max exclusive. A zero Max
+	// exports the whole world.
+	Min, Max blockworld.Point
+	// Quantizer picks the 255 palette colors. Nil uses MedianCutQuantizer.
+	Quantizer Quantizer
+}
+
+// SaveVox writes a region of world to path as a MagicaVoxel .vox file.
+func SaveVox(path string, world *blockworld.Blockworld, opts VoxOptions) error {
+	data, err := EncodeVox(world, opts)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// EncodeVox serializes a region of world into the MagicaVoxel .vox format.
+// The region is split into models of at most 256 blocks per edge, which a
+// scene graph places next to each other, and the block colors are reduced
+// to the 255 colors of a .vox palette. The palette is opaque, the alpha of
+// VXL colors darkens them instead.
+func EncodeVox(world *blockworld.Blockworld, opts VoxOptions) ([]byte, error) {
+	lo, hi := opts.Min, opts.Max
+	if hi == (blockworld.Point{}) {
+		hi.X, hi.Y, hi.Z = world.Size()
+	}
+	sx, sy, sz := world.Size()
+	if lo.X < 0 || lo.Y < 0 || lo.Z < 0 || hi.X > sx || hi.Y > sy || hi.Z > sz ||
+		lo.X >= hi.X || lo.Y >= hi.Y || lo.Z >= hi.Z {
+		return nil, fmt.Errorf("vox: invalid region %v-%v for world size %dx%dx%d", lo, hi, sx, sy, sz)
+	}
+	quantizer := opts.Quantizer
+	if quantizer == nil {
+		quantizer = MedianCutQuantizer{}
+	}
+
+	histogram := map[color.NRGBA]int{}
+	for x := lo.X; x < hi.X; x++ {
+		for y := lo.Y; y < hi.Y; y++ {
+			for z := lo.Z; z < hi.Z; z++ {
+				if b, ok := world.GetRaw(x, y, z); ok {
+					histogram[b.Color]++
+				}
+			}
+		}
+	}
+	palette := quantizer.Quantize(histogram, 255)
+	if len(palette) > 255 {
+		return nil, fmt.Errorf("vox: quantizer returned %d colors, want at most 255", len(palette))
+	}
+	// Color indices start at 1, 0 means empty.
+	index := make(map[color.NRGBA]uint8, len(histogram))
+	for c := range histogram {
+		index[c] = uint8(nearestColor(palette, c) + 1)
+	}
+
+	var models, nodes [][]byte
+	var shapes []int
+	for ox := lo.X; ox < hi.X; ox += voxMaxSize {
+		for oy := lo.Y; oy < hi.Y; oy += voxMaxSize {
+			for oz := lo.Z; oz < hi.Z; oz += voxMaxSize {
+				size := [3]int{min(voxMaxSize, hi.X-ox), min(voxMaxSize, hi.Y-oy), min(voxMaxSize, hi.Z-oz)}
+				var voxels []byte
+				for x := 0; x < size[0]; x++ {
+					for y := 0; y < size[1]; y++ {
+						for z := 0; z < size[2]; z++ {
+							if b, ok := world.GetRaw(ox+x, oy+y, oz+z); ok {
+								voxels = append(voxels, uint8(x), uint8(y), uint8(z), index[b.Color])
+							}
+						}
+					}
+				}
+				if voxels == nil {
+					continue
+				}
+
+				m := len(models) / 2
+				models = append(models,
+					encodeVoxChunk("SIZE", appendVoxInts(nil, size[0], size[1], size[2])),
+					encodeVoxChunk("XYZI", append(appendVoxInts(nil, len(voxels)/4), voxels...)))
+
+				// DecodeVox places the center of a model at its translation.
+				t := fmt.Sprintf("%d %d %d", ox-lo.X+size[0]/2, oy-lo.Y+size[1]/2, oz-lo.Z+size[2]/2)
+				trn, shp := 2+2*m, 3+2*m
+				nodes = append(nodes, encodeVoxTRN(trn, shp, "_t", t), encodeVoxSHP(shp, m))
+				shapes = append(shapes, trn)
+			}
+		}
+	}
+
+	// The alpha of VXL colors is shading, which darkens the block in frames.
+	// Fold it into the color, .vox palette alpha is opacity.
+	rgba := make([]byte, 0, 256*4)
+	for i := 0; i < 256; i++ {
+		if i >= len(palette) {
+			rgba = append(rgba, 0, 0, 0, 0)
+			continue
+		}
+		r, g, b, _ := palette[i].RGBA()
+		rgba = append(rgba, uint8(r>>8), uint8(g>>8), uint8(b>>8), 255)
+	}
+
+	var children []byte
+	for _, c := range models {
+		children = append(children, c...)
+	}
+	children = append(children, encodeVoxTRN(0, 1)...)
+	children = append(children, encodeVoxGRP(1, shapes)...)
+	for _, c := range nodes {
+		children = append(children, c...)
+	}
+	children = append(children, encodeVoxChunk("RGBA", rgba)...)
+
+	out := appendVoxInts([]byte("VOX "), 150)
+	out = append(out, "MAIN"...)
+	return append(appendVoxInts(out, 0, len(children)), children...), nil
+}
+
+func appendVoxInts(out []byte, vs ...int) []byte {
+	for _, v := range vs {
+		out = binary.LittleEndian.AppendUint32(out, uint32(int32(v)))
+	}
+	return out
+}
+
+// appendVoxDict appends a DICT holding the key, value pairs in kv.
+func appendVoxDict(out []byte, kv ...string) []byte {
+	out = appendVoxInts(out, len(kv)/2)
+	for _, s := range kv {
+		out = append(appendVoxInts(out, len(s)), s...)
+	}
+	return out
+}
+
+// encodeVoxChunk encodes a chunk without children.
+func encodeVoxChunk(id string, content []byte) []byte {
+	out := appendVoxInts([]byte(id), len(content), 0)
+	return append(out, content...)
+}
+
+// encodeVoxTRN encodes a transform node with a single frame holding kv.
+func encodeVoxTRN(id, child int, kv ...string) []byte {
+	content := appendVoxDict(appendVoxInts(nil, id))
+	content = appendVoxInts(content, child, -1, 0, 1)
+	return encodeVoxChunk("nTRN", appendVoxDict(content, kv...))
+}
+
+func encodeVoxGRP(id int, children []int) []byte {
+	content := appendVoxDict(appendVoxInts(nil, id))
+	content = appendVoxInts(content, len(children))
+	return encodeVoxChunk("nGRP", appendVoxInts(content, children...))
+}
+
+func encodeVoxSHP(id, model int) []byte {
+	content := appendVoxDict(appendVoxInts(nil, id))
+	content = appendVoxInts(content, 1, model)
+	return encodeVoxChunk("nSHP", appendVoxDict(content))
+}
@@ -0,0 +1,77 @@
+package render
+
+import (
+	"math"
+
+	"github.com/pudelkoM/go-render/pkg/blockworld"
+)
+
+// aoLevels is the brightness of a face corner with 0 to 3 of its neighbors
+// open, see vertexAO.
+var aoLevels = [4]float64{0.4, 0.6, 0.8, 1}
+
+// occlusion returns the ambient occlusion term of the face hit at point p,
+// from 0.4 for corners enclosed by blocks to 1 for open faces.
+//
+// Each corner of the face is darkened by the opaque blocks that touch it in
+// the layer of air in front of the face, and the hit point interpolates
+// between the corners, so crevices and inner edges fade in smoothly.
+func (s *scene) occlusion(p blockworld.Vec3, h hit) float64 {
+	// The block that was hit, and its neighbor in front of the face.
+	cell := [3]float64{
+		math.Floor(p.X - h.normal.X/2),
+		math.Floor(p.Y - h.normal.Y/2),
+		math.Floor(p.Z - h.normal.Z/2),
+	}
+	front := [3]int{
+		int(cell[0] + h.normal.X),
+		int(cell[1] + h.normal.Y),
+		int(cell[2] + h.normal.Z),
+	}
+
+	// u and v are the axes along the face, fu and fv where on it p is.
+	u, v := 1, 2
+	switch {
+	case h.normal.Y != 0:
+		u, v = 0, 2
+	case h.normal.Z != 0:
+		u, v = 0, 1
+	}
+	pos := [3]float64{p.X, p.Y, p.Z}
+	fu := min(max(pos[u]-cell[u], 0), 1)
+	fv := min(max(pos[v]-cell[v], 0), 1)
+
+	// Transparent blocks let the ambient light through.
+	set := func(du, dv int) bool {
+		n := front
+		n[u] += du
+		n[v] += dv
+		b, ok := s.world.GetRaw(n[0], n[1], n[2])
+		return ok && !b.Transparent()
+	}
+	corner := func(du, dv int) float64 {
+		return vertexAO(set(du, 0), set(0, dv), set(du, dv))
+	}
+	return (1-fv)*((1-fu)*corner(-1, -1)+fu*corner(1, -1)) +
+		fv*((1-fu)*corner(-1, 1)+fu*corner(1, 1))
+}
+
+// vertexAO returns the brightness of a face corner from whether the two
+// blocks along its edges and the one diagonal to it are set. Two set edge
+// blocks close the corner off, whatever the diagonal is.
+func vertexAO(side1, side2, diagonal bool) float64 {
+	if side1 && side2 {
+		return aoLevels[0]
+	}
+	open := 3
+	if side1 {
+		open--
+	}
+	if side2 {
+		open--
+	}
+	if diagonal {
+		open--
+	}
+	return aoLevels[open]
+}
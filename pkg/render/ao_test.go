@@ -0,0 +1,66 @@
+package render
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/pudelkoM/go-render/pkg/blockworld"
+)
+
+func TestVertexAO(t *testing.T) {
+	for _, tt := range []struct {
+		side1, side2, diagonal bool
+		want                   float64
+	}{
+		{false, false, false, 1},
+		{false, false, true, 0.8},
+		{true, false, false, 0.8},
+		{true, false, true, 0.6},
+		{false, true, true, 0.6},
+		{true, true, false, 0.4},
+		{true, true, true, 0.4},
+	} {
+		if got := vertexAO(tt.side1, tt.side2, tt.diagonal); got != tt.want {
+			t.Errorf("vertexAO(%v, %v, %v) = %g, want %g", tt.side1, tt.side2, tt.diagonal, got, tt.want)
+		}
+	}
+}
+
+func TestOcclusion(t *testing.T) {
+	// A floor with its top at z = 2 and two blocks on it, forming an inner
+	// corner around the floor block at 4, 5.
+	world := blockworld.NewBlockworld()
+	world.SetSize(16, 16, 8)
+	for x := 1; x < 15; x++ {
+		for y := 1; y < 15; y++ {
+			world.Set(x, y, 1, blockworld.Block{})
+		}
+	}
+	world.Set(5, 5, 2, blockworld.Block{})
+	world.Set(4, 6, 2, blockworld.Block{})
+	world.Set(10, 3, 2, blockworld.Block{Color: color.NRGBA{B: 255, A: 128}, Flags: blockworld.FlagTransparent})
+	s := NewRenderer(world, Camera{}, DefaultOptions()).scene()
+	up := blockworld.Vec3{Z: 1}
+
+	for _, tt := range []struct {
+		name   string
+		p      blockworld.Vec3
+		normal blockworld.Vec3
+		want   float64
+	}{
+		{"open floor", blockworld.Vec3{X: 10.5, Y: 10.5, Z: 2}, up, 1},
+		{"next to one block", blockworld.Vec3{X: 6, Y: 5.5, Z: 2}, up, 0.8},
+		{"next to glass", blockworld.Vec3{X: 11, Y: 3.5, Z: 2}, up, 1},
+		{"inner corner", blockworld.Vec3{X: 5, Y: 6, Z: 2}, up, 0.4},
+		// The average of its corners: 1, 0.8 and 0.8 next to one block
+		// and 0.4 in the inner corner.
+		{"center of the corner block", blockworld.Vec3{X: 4.5, Y: 5.5, Z: 2}, up, 0.75},
+		// Half of it touches the floor.
+		{"side of a block", blockworld.Vec3{X: 6, Y: 5.5, Z: 2.5}, blockworld.Vec3{X: 1}, 0.8},
+	} {
+		if got := s.occlusion(tt.p, hit{normal: tt.normal}); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: occlusion %g, want %g", tt.name, got, tt.want)
+		}
+	}
+}
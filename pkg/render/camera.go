@@ -0,0 +1,136 @@
+package render
+
+import (
+	"math"
+
+	"github.com/pudelkoM/go-render/pkg/blockworld"
+)
+
+// DefaultFovHDeg is the horizontal field of view of a Camera without one.
+const DefaultFovHDeg = 55
+
+// Projection selects how a Camera maps pixels to rays.
+type Projection int
+
+const (
+	ProjectionPerspective  Projection = iota // rays fan out from Pos
+	ProjectionOrthographic                   // parallel rays along Dir from a plane through Pos
+	ProjectionIsometric                      // orthographic from the isometric angle closest to Dir
+	// ProjectionEquirectangular maps the full sphere around Pos to a 2:1
+	// frame, with longitude along x and latitude along y. The center column
+	// looks towards Dir.Phi, row 0 straight up; Dir.Theta is ignored, so the
+	// horizon is always level.
+	ProjectionEquirectangular
+	// ProjectionRectilinear is a pinhole perspective that keeps straight
+	// lines straight, like the faces of a cubemap. ProjectionPerspective
+	// instead spaces rays by equal angles.
+	ProjectionRectilinear
+)
+
+// isometricTheta looks down at the angle under which the three axes appear
+// equally long.
+var isometricTheta = 90 + math.Atan(1/math.Sqrt2)*180/math.Pi
+
+// Camera is the point of view a frame is rendered from.
+type Camera struct {
+	Pos        blockworld.Vec3
+	Dir        blockworld.Angle3
+	Projection Projection
+	// FovHDeg is the horizontal field of view in degrees of perspective and
+	// rectilinear cameras, zero means DefaultFovHDeg. The vertical one
+	// follows from the aspect ratio of the frame, so pixels are square.
+	FovHDeg float64
+	// Zoom is the size of a block in pixels for the parallel projections,
+	// zero means 1.
+	Zoom float64
+}
+
+// MinZoom is the smallest Zoom that ZoomBy goes down to.
+const MinZoom = 0.01
+
+// ZoomBy multiplies the zoom of the parallel projections by factor, not going
+// below MinZoom.
+func (c *Camera) ZoomBy(factor float64) {
+	zoom := c.Zoom
+	if zoom == 0 {
+		zoom = 1
+	}
+	c.Zoom = max(zoom*factor, MinZoom)
+}
+
+// viewDir returns the direction of the center ray.
+func (c Camera) viewDir() blockworld.Angle3 {
+	if c.Projection != ProjectionIsometric {
+		return c.Dir
+	}
+	return blockworld.Angle3{Theta: isometricTheta, Phi: 45 + 90*math.Round((c.Dir.Phi-45)/90)}
+}
+
+// fovHDeg returns the horizontal field of view in degrees.
+func (c Camera) fovHDeg() float64 {
+	if c.FovHDeg == 0 {
+		return DefaultFovHDeg
+	}
+	return c.FovHDeg
+}
+
+// basis returns the unit vectors pointing along dir and, seen from there,
+// towards the right and the bottom of a frame.
+func basis(dir blockworld.Angle3) (forward, right, down blockworld.Vec3) {
+	rotate := func(v blockworld.Vec3) blockworld.Vec3 {
+		return v.RotateY(dir.Theta - 90).RotateZ(dir.Phi)
+	}
+	return rotate(blockworld.Vec3{X: 1}), rotate(blockworld.Vec3{Y: 1}), rotate(blockworld.Vec3{Z: -1})
+}
+
+// Ray returns the direction of the ray through pixel x, y of a w x h frame,
+// with row 0 at the top. For even w and h, pixel w/2, h/2 looks along Dir in
+// perspective and parallel projections.
+func (c Camera) Ray(x, y, w, h int) blockworld.Vec3 {
+	if c.Projection == ProjectionRectilinear {
+		// Sample pixel centers, so the edges of adjacent 90° frames meet.
+		forward, right, down := basis(c.Dir)
+		tanH := math.Tan(c.fovHDeg() / 2 * math.Pi / 180)
+		u := (2*(float64(x)+0.5)/float64(w) - 1) * tanH
+		v := (2*(float64(y)+0.5)/float64(h) - 1) * tanH * float64(h) / float64(w)
+		return forward.Add(right.Mul(u)).Add(down.Mul(v)).Normalize()
+	}
+	if c.Projection == ProjectionEquirectangular {
+		// Sample pixel centers, so the poles do not fill whole rows and
+		// the left and right edges meet.
+		return blockworld.Angle3{
+			Theta: (float64(y) + 0.5) / float64(h) * 180,
+			Phi:   c.Dir.Phi - 180 + (float64(x)+0.5)/float64(w)*360,
+		}.ToCartesianVec3(1)
+	}
+	dir := c.viewDir()
+	var xd, yd float64
+	if c.Projection == ProjectionPerspective {
+		fovHDeg := c.fovHDeg()
+		fovVDeg := fovHDeg * float64(h) / float64(w)
+		degPerPixel := fovHDeg / float64(w)
+
+		yd = (-fovVDeg / 2) + float64(y)*degPerPixel
+		xd = (-fovHDeg / 2) + float64(x)*degPerPixel
+	}
+	return blockworld.Vec3{X: 1, Y: 0, Z: 0}.
+		RotateY(yd).RotateZ(xd).
+		RotateY(dir.Theta - 90).RotateZ(dir.Phi)
+}
+
+// Origin returns the start of the ray through pixel x, y of a w x h frame.
+// Parallel rays start on the plane through Pos facing Dir, Zoom pixels apart
+// per block, all others at Pos.
+func (c Camera) Origin(x, y, w, h int) blockworld.Vec3 {
+	if c.Projection != ProjectionOrthographic && c.Projection != ProjectionIsometric {
+		return c.Pos
+	}
+	zoom := c.Zoom
+	if zoom == 0 {
+		zoom = 1
+	}
+	_, right, down := basis(c.viewDir())
+	return c.Pos.
+		Add(right.Mul((float64(x) - float64(w)/2) / zoom)).
+		Add(down.Mul((float64(y) - float64(h)/2) / zoom))
+}
@@ -0,0 +1,145 @@
+package render_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/pudelkoM/go-render/pkg/blockworld"
+	"github.com/pudelkoM/go-render/pkg/render"
+)
+
+func vecAlmostEqual(a, b blockworld.Vec3) bool {
+	const eps = 1e-9
+	return math.Abs(a.X-b.X) < eps && math.Abs(a.Y-b.Y) < eps && math.Abs(a.Z-b.Z) < eps
+}
+
+func TestCameraCenterRay(t *testing.T) {
+	for _, dir := range []blockworld.Angle3{
+		{Theta: 90, Phi: 0},
+		{Theta: 90, Phi: 90},
+		{Theta: 45, Phi: 30},
+		{Theta: 95, Phi: 325},
+		{Theta: 170, Phi: 200},
+	} {
+		for _, size := range [][2]int{{64, 48}, {640, 480}, {100, 300}} {
+			c := render.Camera{Dir: dir, FovHDeg: 70}
+			got := c.Ray(size[0]/2, size[1]/2, size[0], size[1])
+			if want := dir.ToCartesianVec3(1); !vecAlmostEqual(got, want) {
+				t.Errorf("Camera{Dir: %v}.Ray() at the center of %dx%d = %v, want %v", dir, size[0], size[1], got, want)
+			}
+		}
+	}
+}
+
+func TestCameraFov(t *testing.T) {
+	// Looking along X, the left edge of the frame is rotated by half of the
+	// horizontal field of view, the top edge by half of the vertical one.
+	c := render.Camera{Dir: blockworld.Angle3{Theta: 90, Phi: 0}, FovHDeg: 60}
+	if got, want := c.Ray(0, 50, 200, 100), (blockworld.Angle3{Theta: 90, Phi: -30}).ToCartesianVec3(1); !vecAlmostEqual(got, want) {
+		t.Errorf("left edge ray = %v, want %v", got, want)
+	}
+	if got, want := c.Ray(100, 0, 200, 100), (blockworld.Angle3{Theta: 75, Phi: 0}).ToCartesianVec3(1); !vecAlmostEqual(got, want) {
+		t.Errorf("top edge ray = %v, want %v", got, want)
+	}
+
+	// A zero field of view is the default one.
+	def := render.Camera{Dir: c.Dir, FovHDeg: render.DefaultFovHDeg}
+	if got, want := (render.Camera{Dir: c.Dir}).Ray(0, 0, 64, 48), def.Ray(0, 0, 64, 48); got != want {
+		t.Errorf("ray without field of view = %v, want %v", got, want)
+	}
+}
+
+func TestCameraParallel(t *testing.T) {
+	const w, h = 40, 30
+	for _, tt := range []struct {
+		name    string
+		camera  render.Camera
+		wantDir blockworld.Vec3
+	}{
+		{
+			name:    "orthographic",
+			camera:  render.Camera{Pos: blockworld.Vec3{X: 5, Y: 6, Z: 7}, Dir: blockworld.Angle3{Theta: 120, Phi: 30}, Projection: render.ProjectionOrthographic, Zoom: 4},
+			wantDir: blockworld.Angle3{Theta: 120, Phi: 30}.ToCartesianVec3(1),
+		},
+		{
+			name:    "isometric",
+			camera:  render.Camera{Pos: blockworld.Vec3{X: 5, Y: 6, Z: 7}, Dir: blockworld.Angle3{Theta: 90, Phi: 200}, Projection: render.ProjectionIsometric},
+			wantDir: blockworld.Vec3{X: -1, Y: -1, Z: -1}.Normalize(),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			zoom := max(tt.camera.Zoom, 1)
+			if got := tt.camera.Origin(w/2, h/2, w, h); !vecAlmostEqual(got, tt.camera.Pos) {
+				t.Errorf("center origin = %v, want %v", got, tt.camera.Pos)
+			}
+			for _, p := range [][2]int{{0, 0}, {w - 1, 0}, {7, 19}} {
+				if got := tt.camera.Ray(p[0], p[1], w, h); !vecAlmostEqual(got, tt.wantDir) {
+					t.Errorf("ray at %v = %v, want %v", p, got, tt.wantDir)
+				}
+				// Neighboring pixels are 1/zoom blocks apart on a plane
+				// facing the view direction.
+				o := tt.camera.Origin(p[0], p[1], w, h)
+				for _, d := range []blockworld.Vec3{
+					tt.camera.Origin(p[0]+1, p[1], w, h).Sub(o),
+					tt.camera.Origin(p[0], p[1]+1, w, h).Sub(o),
+				} {
+					if l := math.Sqrt(d.X*d.X + d.Y*d.Y + d.Z*d.Z); math.Abs(l-1/zoom) > 1e-9 {
+						t.Errorf("pixel spacing at %v = %g, want %g", p, l, 1/zoom)
+					}
+					if dot := d.X*tt.wantDir.X + d.Y*tt.wantDir.Y + d.Z*tt.wantDir.Z; math.Abs(dot) > 1e-9 {
+						t.Errorf("pixel offset %v at %v is not perpendicular to the view direction", d, p)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCameraEquirectangular(t *testing.T) {
+	const w, h = 360, 180
+	c := render.Camera{Dir: blockworld.Angle3{Theta: 30, Phi: 40}, Projection: render.ProjectionEquirectangular}
+	for _, tt := range []struct {
+		x, y int
+		want blockworld.Angle3
+	}{
+		{180, 89, blockworld.Angle3{Theta: 89.5, Phi: 40.5}},   // center, level despite Theta
+		{0, 89, blockworld.Angle3{Theta: 89.5, Phi: -139.5}},   // behind
+		{359, 89, blockworld.Angle3{Theta: 89.5, Phi: 219.5}},  // behind, from the other side
+		{90, 89, blockworld.Angle3{Theta: 89.5, Phi: -49.5}},   // left
+		{270, 89, blockworld.Angle3{Theta: 89.5, Phi: 130.5}},  // right
+		{180, 0, blockworld.Angle3{Theta: 0.5, Phi: 40.5}},     // up
+		{180, 179, blockworld.Angle3{Theta: 179.5, Phi: 40.5}}, // down
+	} {
+		if got, want := c.Ray(tt.x, tt.y, w, h), tt.want.ToCartesianVec3(1); !vecAlmostEqual(got, want) {
+			t.Errorf("ray at %d, %d = %v, want %v", tt.x, tt.y, got, want)
+		}
+		if got := c.Origin(tt.x, tt.y, w, h); got != c.Pos {
+			t.Errorf("origin at %d, %d = %v, want %v", tt.x, tt.y, got, c.Pos)
+		}
+	}
+}
+
+func TestCameraZoomBy(t *testing.T) {
+	var c render.Camera
+	c.ZoomBy(0.5)
+	if c.Zoom != 0.5 {
+		t.Errorf("zooming out of the default zoom: %g, want 0.5", c.Zoom)
+	}
+	// Far enough out to see a whole 512 block map in a small window.
+	for i := 0; i < 50; i++ {
+		c.ZoomBy(1 / 1.05)
+	}
+	if c.Zoom >= 0.1 {
+		t.Errorf("zoom after zooming out 50 times: %g, want below 0.1", c.Zoom)
+	}
+	for i := 0; i < 1000; i++ {
+		c.ZoomBy(1 / 1.05)
+	}
+	if c.Zoom != render.MinZoom {
+		t.Errorf("zoom after zooming out 1000 times: %g, want %g", c.Zoom, render.MinZoom)
+	}
+	c.ZoomBy(2)
+	if c.Zoom != 2*render.MinZoom {
+		t.Errorf("zooming in from MinZoom: %g, want %g", c.Zoom, 2*render.MinZoom)
+	}
+}
@@ -0,0 +1,97 @@
+package render
+
+import (
+	"image"
+	"image/draw"
+
+	"github.com/pudelkoM/go-render/pkg/blockworld"
+)
+
+// CubeFace is one of the six faces of a cubemap, named after the axis it
+// looks along.
+//
+// The four side faces have +Z at the top of the image; turning right goes
+// from +X to +Y to -X to -Y. The +Z face has +X at the bottom and +Y on the
+// right, the -Z face has +X at the top and +Y on the right, so both continue
+// the +X face, like looking up and down from it.
+type CubeFace int
+
+const (
+	FacePosX CubeFace = iota // right: +Y, down: -Z
+	FaceNegX                 // right: -Y, down: -Z
+	FacePosY                 // right: -X, down: -Z
+	FaceNegY                 // right: +X, down: -Z
+	FacePosZ                 // right: +Y, down: +X
+	FaceNegZ                 // right: +Y, down: -X
+)
+
+// Dir returns the direction the face looks along.
+func (f CubeFace) Dir() blockworld.Angle3 {
+	switch f {
+	case FaceNegX:
+		return blockworld.Angle3{Theta: 90, Phi: 180}
+	case FacePosY:
+		return blockworld.Angle3{Theta: 90, Phi: 90}
+	case FaceNegY:
+		return blockworld.Angle3{Theta: 90, Phi: 270}
+	case FacePosZ:
+		return blockworld.Angle3{Theta: 0, Phi: 0}
+	case FaceNegZ:
+		return blockworld.Angle3{Theta: 180, Phi: 0}
+	}
+	return blockworld.Angle3{Theta: 90, Phi: 0}
+}
+
+// String returns the short name of the face, such as "px" for FacePosX or
+// "nz" for FaceNegZ.
+func (f CubeFace) String() string {
+	return [...]string{"px", "nx", "py", "ny", "pz", "nz"}[f]
+}
+
+// crossCells is the cell of each face in the 4 x 3 cell layout of
+// CubemapCross:
+//
+//	    +Z
+//	-Y  +X  +Y  -X
+//	    -Z
+var crossCells = [6]image.Point{
+	FacePosX: {1, 1},
+	FaceNegX: {3, 1},
+	FacePosY: {2, 1},
+	FaceNegY: {0, 1},
+	FacePosZ: {1, 0},
+	FaceNegZ: {1, 2},
+}
+
+// Cubemap renders the six size x size faces of the cube around pos, indexed
+// by CubeFace, with the options of r. Like Render, it builds r.Octree if the
+// options need one and there is none yet.
+func (r *Renderer) Cubemap(pos blockworld.Vec3, size int) [6]*image.RGBA {
+	var faces [6]*image.RGBA
+	face := *r
+	for f := range faces {
+		face.Camera = Camera{
+			Pos:        pos,
+			Dir:        CubeFace(f).Dir(),
+			Projection: ProjectionRectilinear,
+			FovHDeg:    90,
+		}
+		faces[f] = face.Frame(size, size)
+	}
+	// Keep an octree built for the faces for the next render.
+	r.Octree = face.Octree
+	return faces
+}
+
+// CubemapCross arranges the faces returned by Cubemap in a horizontal cross,
+// with the side faces in a row from -Y to -X and the +Z and -Z faces above
+// and below +X. The remaining cells are transparent.
+func CubemapCross(faces [6]*image.RGBA) *image.RGBA {
+	size := faces[FacePosX].Rect.Dx()
+	img := image.NewRGBA(image.Rect(0, 0, 4*size, 3*size))
+	for f, face := range faces {
+		min := crossCells[f].Mul(size)
+		draw.Draw(img, image.Rectangle{min, min.Add(image.Pt(size, size))}, face, face.Rect.Min, draw.Src)
+	}
+	return img
+}
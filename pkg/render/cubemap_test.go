@@ -0,0 +1,101 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/pudelkoM/go-render/pkg/blockworld"
+)
+
+func TestCubemapFaces(t *testing.T) {
+	// A closed box with a differently colored wall on every side.
+	walls := [6]color.NRGBA{
+		FacePosX: {R: 255, A: 255},
+		FaceNegX: {G: 255, A: 255},
+		FacePosY: {B: 255, A: 255},
+		FaceNegY: {R: 255, G: 255, A: 255},
+		FacePosZ: {G: 255, B: 255, A: 255},
+		FaceNegZ: {R: 255, B: 255, A: 255},
+	}
+	world := blockworld.NewBlockworld()
+	world.SetSize(18, 18, 18)
+	for a := 1; a <= 16; a++ {
+		for b := 1; b <= 16; b++ {
+			world.Set(16, a, b, blockworld.Block{Color: walls[FacePosX]})
+			world.Set(1, a, b, blockworld.Block{Color: walls[FaceNegX]})
+			world.Set(a, 16, b, blockworld.Block{Color: walls[FacePosY]})
+			world.Set(a, 1, b, blockworld.Block{Color: walls[FaceNegY]})
+			world.Set(a, b, 16, blockworld.Block{Color: walls[FacePosZ]})
+			world.Set(a, b, 1, blockworld.Block{Color: walls[FaceNegZ]})
+		}
+	}
+
+	r := NewRenderer(world, Camera{}, DefaultOptions())
+	faces := r.Cubemap(blockworld.Vec3{X: 8.5, Y: 8.5, Z: 8.5}, 16)
+	for f, face := range faces {
+		want := walls[f]
+		if got := face.RGBAAt(8, 8); got != (color.RGBA{want.R, want.G, want.B, want.A}) {
+			t.Errorf("face %v: center %v, want %v", CubeFace(f), got, want)
+		}
+	}
+
+	cross := CubemapCross(faces)
+	if cross.Rect != image.Rect(0, 0, 64, 48) {
+		t.Fatalf("cross is %v, want 64x48", cross.Rect)
+	}
+	if got := cross.RGBAAt(8, 8); got != (color.RGBA{}) {
+		t.Errorf("unused cell is %v, want transparent", got)
+	}
+	for f, cell := range crossCells {
+		if got, want := cross.RGBAAt(cell.X*16+8, cell.Y*16+8), faces[f].RGBAAt(8, 8); got != want {
+			t.Errorf("face %v in cross: %v, want %v", CubeFace(f), got, want)
+		}
+	}
+}
+
+func TestCubemapSeams(t *testing.T) {
+	// Neighboring pixels across an edge of two faces in the cross have to
+	// look in neighboring directions, about one pixel apart.
+	const size = 32
+	faceAt := map[image.Point]CubeFace{}
+	for f, cell := range crossCells {
+		faceAt[cell] = CubeFace(f)
+	}
+	ray := func(x, y int) (blockworld.Vec3, bool) {
+		f, ok := faceAt[image.Pt(x/size, y/size)]
+		c := Camera{Dir: f.Dir(), Projection: ProjectionRectilinear, FovHDeg: 90}
+		return c.Ray(x%size, y%size, size, size), ok
+	}
+	maxAngle := 1.5 * math.Pi / 2 / size
+	seams := 0
+	for y := 0; y < 3*size; y++ {
+		for x := 0; x < 4*size; x++ {
+			a, ok := ray(x, y)
+			if !ok {
+				continue
+			}
+			var across []image.Point
+			if (x+1)%size == 0 {
+				across = append(across, image.Pt(x+1, y))
+			}
+			if (y+1)%size == 0 {
+				across = append(across, image.Pt(x, y+1))
+			}
+			for _, n := range across {
+				b, ok := ray(n.X, n.Y)
+				if !ok {
+					continue
+				}
+				seams++
+				if angle := math.Acos(min(a.Dot(b), 1)); angle > maxAngle {
+					t.Fatalf("pixels %d,%d and %v are %.3f rad apart", x, y, n, angle)
+				}
+			}
+		}
+	}
+	if seams != 5*size {
+		t.Errorf("checked %d pixel pairs, want %d", seams, 5*size)
+	}
+}
@@ -0,0 +1,274 @@
+package render_test
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pudelkoM/go-render/pkg/blockworld"
+	"github.com/pudelkoM/go-render/pkg/render"
+)
+
+var update = flag.Bool("update", false, "regenerate the golden images in testdata/")
+
+const (
+	// goldenTolerance is the largest per-channel difference a pixel may
+	// have before it counts as a mismatch.
+	goldenTolerance = 2
+	// goldenMaxMismatch is the fraction of pixels allowed to mismatch. Edge
+	// pixels can flip between blocks when float rounding differs slightly
+	// between architectures.
+	goldenMaxMismatch = 0.005
+)
+
+// wallWorld is the wall generated by Blockworld.RandomizeWith.
+func wallWorld(seed int64) *blockworld.Blockworld {
+	world := blockworld.NewBlockworld()
+	world.SetSize(16, 16, 8)
+	world.RandomizeWith(rand.New(rand.NewSource(seed)))
+	return world
+}
+
+// terrainWorld is a small random height map with a flat floor at z = 0.
+func terrainWorld(seed int64) *blockworld.Blockworld {
+	rng := rand.New(rand.NewSource(seed))
+	world := blockworld.NewBlockworld()
+	world.SetSize(32, 32, 16)
+	for x := 0; x < 32; x++ {
+		for y := 0; y < 32; y++ {
+			world.Set(x, y, 0, blockworld.Block{Color: color.NRGBA{R: 40, G: 90, B: 160, A: 255}})
+			if rng.Intn(4) != 0 {
+				continue
+			}
+			height := 1 + rng.Intn(8)
+			c := color.NRGBA{R: uint8(rng.Intn(256)), G: uint8(rng.Intn(256)), B: uint8(rng.Intn(256)), A: 255}
+			for z := 1; z <= height; z++ {
+				world.Set(x, y, z, blockworld.Block{Color: c})
+			}
+		}
+	}
+	return world
+}
+
+// withWater makes the floor at z = 0 reflective, like the water layer of
+// maps loaded by maploader.
+func withWater(world *blockworld.Blockworld) *blockworld.Blockworld {
+	sx, sy, _ := world.Size()
+	for x := 0; x < sx; x++ {
+		for y := 0; y < sy; y++ {
+			if b, ok := world.GetRaw(x, y, 0); ok {
+				water := *b
+				water.Flags |= blockworld.FlagReflective
+				world.Set(x, y, 0, water)
+			}
+		}
+	}
+	return world
+}
+
+// withGlass turns every fourth row of columns into blue glass.
+func withGlass(world *blockworld.Blockworld) *blockworld.Blockworld {
+	sx, sy, sz := world.Size()
+	for x := 0; x < sx; x += 4 {
+		for y := 0; y < sy; y++ {
+			for z := 1; z < sz; z++ {
+				if _, ok := world.GetRaw(x, y, z); ok {
+					world.Set(x, y, z, blockworld.Block{Color: color.NRGBA{R: 150, G: 200, B: 255, A: 100}, Flags: blockworld.FlagTransparent})
+				}
+			}
+		}
+	}
+	return world
+}
+
+func TestGolden(t *testing.T) {
+	depth := render.DefaultOptions()
+	depth.Mode = render.ModeDepth
+	lit := render.DefaultOptions()
+	lit.Lighting = true
+	shadows := lit
+	shadows.Shadows = true
+	ao := render.DefaultOptions()
+	ao.Mode = render.ModeAO
+	reflections := render.DefaultOptions()
+	reflections.Reflectivity = render.DefaultReflectivity
+	reflections.MaxBounces = render.DefaultMaxBounces
+
+	tests := []struct {
+		name   string
+		world  *blockworld.Blockworld
+		camera render.Camera
+		opts   render.Options
+		size   image.Point // of the frame, zero means 64x48
+	}{
+		{
+			name:   "wall_front",
+			world:  wallWorld(1),
+			camera: render.Camera{Pos: blockworld.Vec3{X: 0.5, Y: 2.5, Z: 2.5}, Dir: blockworld.Angle3{Theta: 90, Phi: 0}},
+			opts:   render.DefaultOptions(),
+		},
+		{
+			name:   "wall_oblique",
+			world:  wallWorld(2),
+			camera: render.Camera{Pos: blockworld.Vec3{X: 0.5, Y: 9.5, Z: 4.5}, Dir: blockworld.Angle3{Theta: 100, Phi: 300}},
+			opts:   render.DefaultOptions(),
+		},
+		{
+			name:   "terrain",
+			world:  terrainWorld(3),
+			camera: render.Camera{Pos: blockworld.Vec3{X: 2.5, Y: 2.5, Z: 12.5}, Dir: blockworld.Angle3{Theta: 120, Phi: 45}},
+			opts:   render.DefaultOptions(),
+		},
+		{
+			name:   "terrain_depth",
+			world:  terrainWorld(3),
+			camera: render.Camera{Pos: blockworld.Vec3{X: 2.5, Y: 2.5, Z: 12.5}, Dir: blockworld.Angle3{Theta: 120, Phi: 45}},
+			opts:   depth,
+		},
+		{
+			name:   "terrain_lit",
+			world:  terrainWorld(3),
+			camera: render.Camera{Pos: blockworld.Vec3{X: 2.5, Y: 2.5, Z: 12.5}, Dir: blockworld.Angle3{Theta: 120, Phi: 45}},
+			opts:   lit,
+		},
+		{
+			name:   "terrain_shadows",
+			world:  terrainWorld(3),
+			camera: render.Camera{Pos: blockworld.Vec3{X: 2.5, Y: 2.5, Z: 12.5}, Dir: blockworld.Angle3{Theta: 120, Phi: 45}},
+			opts:   shadows,
+		},
+		{
+			name:   "terrain_ao",
+			world:  terrainWorld(3),
+			camera: render.Camera{Pos: blockworld.Vec3{X: 2.5, Y: 2.5, Z: 12.5}, Dir: blockworld.Angle3{Theta: 120, Phi: 45}},
+			opts:   ao,
+		},
+		{
+			name:   "terrain_reflections",
+			world:  withWater(terrainWorld(3)),
+			camera: render.Camera{Pos: blockworld.Vec3{X: 2.5, Y: 2.5, Z: 12.5}, Dir: blockworld.Angle3{Theta: 120, Phi: 45}},
+			opts:   reflections,
+		},
+		{
+			name:   "terrain_glass",
+			world:  withGlass(terrainWorld(3)),
+			camera: render.Camera{Pos: blockworld.Vec3{X: 2.5, Y: 2.5, Z: 12.5}, Dir: blockworld.Angle3{Theta: 120, Phi: 45}},
+			opts:   lit,
+		},
+		{
+			name:   "terrain_top",
+			world:  terrainWorld(3),
+			camera: render.Camera{Pos: blockworld.Vec3{X: 16, Y: 16, Z: 20}, Dir: blockworld.Angle3{Theta: 180, Phi: 0}, Projection: render.ProjectionOrthographic, Zoom: 2},
+			opts:   render.DefaultOptions(),
+		},
+		{
+			name:   "terrain_isometric",
+			world:  terrainWorld(3),
+			camera: render.Camera{Pos: blockworld.Vec3{X: 33.3, Y: 33.3, Z: 21.3}, Dir: blockworld.Angle3{Theta: 90, Phi: 225}, Projection: render.ProjectionIsometric, Zoom: 1.5},
+			opts:   render.DefaultOptions(),
+		},
+		{
+			name:   "terrain_panorama",
+			world:  terrainWorld(3),
+			camera: render.Camera{Pos: blockworld.Vec3{X: 16.5, Y: 16.5, Z: 4.5}, Dir: blockworld.Angle3{Theta: 90, Phi: 0}, Projection: render.ProjectionEquirectangular},
+			opts:   render.DefaultOptions(),
+			size:   image.Pt(96, 48),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size := tt.size
+			if size == (image.Point{}) {
+				size = image.Pt(64, 48)
+			}
+			got := render.NewRenderer(tt.world, tt.camera, tt.opts).Frame(size.X, size.Y)
+			checkGolden(t, tt.name, got)
+		})
+	}
+}
+
+// checkGolden compares img against testdata/<name>.png, or rewrites the
+// golden file when the -update flag is set.
+func checkGolden(t *testing.T, name string, img image.Image) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".png")
+
+	if *update {
+		if err := writePNG(path, img); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := readPNG(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if err := diffImages(img, want); err != nil {
+		actual := filepath.Join(t.TempDir(), name+".png")
+		if werr := writePNG(actual, img); werr != nil {
+			t.Fatal(werr)
+		}
+		t.Errorf("%s: %v, actual frame written to %s", path, err, actual)
+	}
+}
+
+func diffImages(got, want image.Image) error {
+	if got.Bounds() != want.Bounds() {
+		return fmt.Errorf("size mismatch: got %v, want %v", got.Bounds(), want.Bounds())
+	}
+	b := got.Bounds()
+	mismatches := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			g := color.NRGBAModel.Convert(got.At(x, y)).(color.NRGBA)
+			w := color.NRGBAModel.Convert(want.At(x, y)).(color.NRGBA)
+			if absDiff(g.R, w.R) > goldenTolerance || absDiff(g.G, w.G) > goldenTolerance ||
+				absDiff(g.B, w.B) > goldenTolerance || absDiff(g.A, w.A) > goldenTolerance {
+				mismatches++
+			}
+		}
+	}
+	if float64(mismatches) > goldenMaxMismatch*float64(b.Dx()*b.Dy()) {
+		return fmt.Errorf("%d of %d pixels differ", mismatches, b.Dx()*b.Dy())
+	}
+	return nil
+}
+
+func absDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+func writePNG(path string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
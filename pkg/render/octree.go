@@ -0,0 +1,213 @@
+package render
+
+import (
+	"math"
+
+	"github.com/pudelkoM/go-render/pkg/blockworld"
+)
+
+// Child values of octNode besides node indices.
+const (
+	octEmpty int32 = 0  // no set block in the subtree
+	octFull  int32 = -1 // every block of the subtree is set
+)
+
+// octNode holds the eight children of a node, indexed by
+// x | y<<1 | z<<2 where each bit selects the upper half of that axis.
+type octNode [8]int32
+
+// Octree is a sparse voxel octree over the set blocks of a world. Empty and
+// completely filled subtrees are not stored, so rays can skip large empty
+// areas in a single step.
+type Octree struct {
+	world *blockworld.Blockworld
+	nodes []octNode
+	size  int // edge length of the root, a power of two
+	root  int32
+}
+
+// NewOctree builds an octree of the blocks currently set in world. It has to
+// be rebuilt after world changes.
+func NewOctree(world *blockworld.Blockworld) *Octree {
+	sx, sy, sz := world.Size()
+	o := &Octree{world: world, size: 1}
+	for o.size < max(sx, sy, sz) {
+		o.size *= 2
+	}
+	// Node 0 is unused, so that 0 can mean empty in children.
+	o.nodes = append(o.nodes, octNode{})
+	o.root = o.build(0, 0, 0, o.size)
+	return o
+}
+
+// Size returns the edge length of the cube covered by the octree.
+func (o *Octree) Size() int {
+	return o.size
+}
+
+// build returns the child value for the subtree at x, y, z of edge size.
+func (o *Octree) build(x, y, z, size int) int32 {
+	sx, sy, sz := o.world.Size()
+	if x >= sx || y >= sy || z >= sz {
+		return octEmpty
+	}
+	if size == 1 {
+		if _, ok := o.world.GetRaw(x, y, z); ok {
+			return octFull
+		}
+		return octEmpty
+	}
+
+	half := size / 2
+	var n octNode
+	empty, full := true, true
+	for i := range n {
+		n[i] = o.build(x+(i&1)*half, y+(i>>1&1)*half, z+(i>>2&1)*half, half)
+		empty = empty && n[i] == octEmpty
+		full = full && n[i] == octFull
+	}
+	switch {
+	case empty:
+		return octEmpty
+	case full:
+		return octFull
+	}
+	o.nodes = append(o.nodes, n)
+	return int32(len(o.nodes) - 1)
+}
+
+// lookup returns whether the block at p is set. If it is not, it also
+// returns the origin and size of the largest empty node containing p.
+func (o *Octree) lookup(p blockworld.Point) (bool, blockworld.Point, int) {
+	if o.root != octEmpty && o.root != octFull {
+		var origin blockworld.Point
+		node := o.nodes[o.root]
+		for size := o.size / 2; ; size /= 2 {
+			i, child := 0, origin
+			if p.X >= origin.X+size {
+				i |= 1
+				child.X += size
+			}
+			if p.Y >= origin.Y+size {
+				i |= 2
+				child.Y += size
+			}
+			if p.Z >= origin.Z+size {
+				i |= 4
+				child.Z += size
+			}
+			switch node[i] {
+			case octEmpty:
+				return false, child, size
+			case octFull:
+				return true, blockworld.Point{}, 0
+			}
+			node, origin = o.nodes[node[i]], child
+		}
+	}
+	if o.root == octFull {
+		return true, blockworld.Point{}, 0
+	}
+	return false, blockworld.Point{}, o.size
+}
+
+// castRay returns the same hit as castRayAmatidesWoo, but crosses empty
+// octree nodes in one step. Steps still count the voxels the grid traversal
+// would visit, so both reach equally far.
+func (o *Octree) castRay(rayPos, rayDir blockworld.Vec3, maxSteps int) (hit, bool) {
+	pos := [3]float64{rayPos.X, rayPos.Y, rayPos.Z}
+	dir := [3]float64{rayDir.X, rayDir.Y, rayDir.Z}
+	size := float64(o.size)
+
+	// The grid traversal moves to a neighboring voxel in every step and never
+	// turns back, so it reaches a voxel after as many steps as the voxel is
+	// away from its start in Manhattan distance, minus one.
+	start := [3]int{int(math.Floor(pos[0])), int(math.Floor(pos[1])), int(math.Floor(pos[2]))}
+	steps := func(cell [3]int) int {
+		n := -1
+		for a := 0; a < 3; a++ {
+			n += max(cell[a]-start[a], start[a]-cell[a])
+		}
+		return n
+	}
+
+	// axis is the one crossed last, which tells the face a block is hit on.
+	axis := 0
+
+	// Move a ray starting outside of the octree to where it enters it.
+	tEnter, tExit := 0., math.Inf(1)
+	for a := 0; a < 3; a++ {
+		if dir[a] == 0 {
+			if pos[a] < 0 || pos[a] >= size {
+				return hit{}, false
+			}
+			continue
+		}
+		t0, t1 := -pos[a]/dir[a], (size-pos[a])/dir[a]
+		if min(t0, t1) > tEnter {
+			axis, tEnter = a, min(t0, t1)
+		}
+		tExit = min(tExit, max(t0, t1))
+	}
+	if tEnter >= tExit {
+		return hit{}, false
+	}
+	var cell [3]int
+	for a := 0; a < 3; a++ {
+		pos[a] += dir[a] * tEnter
+		cell[a] = min(max(int(math.Floor(pos[a])), 0), o.size-1)
+	}
+	// Like castRayAmatidesWoo, ignore the block the ray starts in.
+	skip := tEnter == 0
+
+	for steps(cell) < maxSteps {
+		if cell[0] < 0 || cell[1] < 0 || cell[2] < 0 || cell[0] >= o.size || cell[1] >= o.size || cell[2] >= o.size {
+			return hit{}, false
+		}
+		p := blockworld.Point{X: cell[0], Y: cell[1], Z: cell[2]}
+		set, origin, nodeSize := o.lookup(p)
+		if set && !skip {
+			b, _ := o.world.Get(p)
+			step := 1
+			if dir[axis] < 0 {
+				step = -1
+			}
+			return hit{block: b, steps: steps(cell), normal: faceNormal(axis, step), dist: faceDist(rayPos, rayDir, p, axis, step)}, true
+		}
+		if set {
+			origin, nodeSize = p, 1
+		}
+		skip = false
+
+		// Leave the empty node through the face the ray hits first.
+		lo := [3]int{origin.X, origin.Y, origin.Z}
+		tMin := math.Inf(1)
+		for a := 0; a < 3; a++ {
+			var t float64
+			switch {
+			case dir[a] > 0:
+				t = (float64(lo[a]+nodeSize) - pos[a]) / dir[a]
+			case dir[a] < 0:
+				t = (float64(lo[a]) - pos[a]) / dir[a]
+			default:
+				continue
+			}
+			if t < tMin {
+				axis, tMin = a, t
+			}
+		}
+		tMin = max(tMin, 0)
+		for a := 0; a < 3; a++ {
+			pos[a] += dir[a] * tMin
+			cell[a] = int(math.Floor(pos[a]))
+		}
+		if dir[axis] > 0 {
+			pos[axis] = float64(lo[axis] + nodeSize)
+			cell[axis] = lo[axis] + nodeSize
+		} else {
+			pos[axis] = float64(lo[axis])
+			cell[axis] = lo[axis] - 1
+		}
+	}
+	return hit{}, false
+}
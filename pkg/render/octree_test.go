@@ -0,0 +1,116 @@
+package render
+
+import (
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"github.com/pudelkoM/go-render/pkg/blockworld"
+	"github.com/pudelkoM/go-render/pkg/maploader"
+)
+
+// sparseWorld has a floor, a few solid boxes and scattered single blocks, so
+// rays cross empty, partially filled and full octree nodes.
+func sparseWorld(rng *rand.Rand) *blockworld.Blockworld {
+	world := blockworld.NewBlockworld()
+	world.SetSize(48, 40, 20)
+	block := func() blockworld.Block {
+		return blockworld.Block{Color: color.NRGBA{R: uint8(rng.Intn(256)), G: uint8(rng.Intn(256)), B: 7, A: 255}}
+	}
+	for x := 0; x < 48; x++ {
+		for y := 0; y < 40; y++ {
+			world.Set(x, y, 0, block())
+		}
+	}
+	for i := 0; i < 5; i++ {
+		x, y, z := rng.Intn(41), rng.Intn(33), rng.Intn(13)
+		for dx := 0; dx < 8; dx++ {
+			for dy := 0; dy < 8; dy++ {
+				for dz := 0; dz < 8; dz++ {
+					world.Set(x+dx, y+dy, z+dz, block())
+				}
+			}
+		}
+	}
+	for i := 0; i < 200; i++ {
+		world.Set(rng.Intn(48), rng.Intn(40), rng.Intn(20), block())
+	}
+	return world
+}
+
+func TestOctreeMatchesGrid(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	world := sparseWorld(rng)
+	octree := NewOctree(world)
+	if octree.Size() != 64 {
+		t.Fatalf("Size() = %d, want 64", octree.Size())
+	}
+
+	for i := 0; i < 20000; i++ {
+		// Include origins outside of the world and inside of blocks.
+		pos := blockworld.Vec3{X: rng.Float64()*64 - 4, Y: rng.Float64()*56 - 4, Z: rng.Float64()*34 - 4}
+		dir := blockworld.Vec3{X: rng.NormFloat64(), Y: rng.NormFloat64(), Z: rng.NormFloat64()}.Normalize()
+		// Sometimes too few steps to reach the first block.
+		maxSteps := 200
+		if i%2 == 0 {
+			maxSteps = 1 + rng.Intn(60)
+		}
+		want, wantOk := castRayAmatidesWoo(world, pos, dir, maxSteps)
+		got, gotOk := octree.castRay(pos, dir, maxSteps)
+		if gotOk != wantOk || got.block != want.block || got.normal != want.normal || got.dist != want.dist || got.steps != want.steps {
+			t.Fatalf("ray %v along %v in %d steps: got %p %v at %g after %d steps, %v, want %p %v at %g after %d steps, %v",
+				pos, dir, maxSteps, got.block, got.normal, got.dist, got.steps, gotOk, want.block, want.normal, want.dist, want.steps, wantOk)
+		}
+	}
+}
+
+func TestOctreeCollapsesNodes(t *testing.T) {
+	world := blockworld.NewBlockworld()
+	world.SetSize(16, 16, 16)
+	if o := NewOctree(world); o.root != octEmpty || len(o.nodes) != 1 {
+		t.Errorf("empty world: root %d with %d nodes", o.root, len(o.nodes))
+	}
+	for x := 0; x < 16; x++ {
+		for y := 0; y < 16; y++ {
+			for z := 0; z < 16; z++ {
+				world.Set(x, y, z, blockworld.Block{})
+			}
+		}
+	}
+	if o := NewOctree(world); o.root != octFull || len(o.nodes) != 1 {
+		t.Errorf("full world: root %d with %d nodes", o.root, len(o.nodes))
+	}
+}
+
+// BenchmarkTraversal renders a frame of DragonsReach.vxl from the viewer's
+// start position with each traversal.
+func BenchmarkTraversal(b *testing.B) {
+	camera := Camera{Pos: blockworld.Vec3{X: 190, Y: 310, Z: 33}, Dir: blockworld.Angle3{Theta: 95, Phi: 325}}
+	for _, tt := range []struct {
+		name      string
+		traversal Traversal
+		distances bool
+	}{
+		{"grid", TraversalGrid, false},
+		{"grid-distance", TraversalGrid, true},
+		{"octree", TraversalOctree, false},
+	} {
+		b.Run(tt.name, func(b *testing.B) {
+			world := blockworld.NewBlockworld()
+			if err := maploader.LoadMap("../../maps/DragonsReach.vxl", world); err != nil {
+				b.Skip(err)
+			}
+			if !tt.distances {
+				clearDistances(world)
+			}
+			opts := DefaultOptions()
+			opts.Traversal = tt.traversal
+			r := NewRenderer(world, camera, opts)
+			img := r.Frame(640, 480) // builds the octree outside of the timing
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				r.Render(img)
+			}
+		})
+	}
+}
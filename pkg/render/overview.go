@@ -0,0 +1,62 @@
+package render
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/pudelkoM/go-render/pkg/blockworld"
+)
+
+// OverviewOptions controls Overview.
+type OverviewOptions struct {
+	// Shade darkens the block colors of lower columns.
+	Shade bool
+	// Colormap, if not nil, replaces the block colors with the column
+	// height mapped through it, for example blockworld.ViridisClamp. Its
+	// argument goes from 0 at the bottom of the world to 1 at the top.
+	Colormap func(t float64) blockworld.Vec3
+}
+
+// Overview returns a top-down view of world with one pixel per column,
+// showing the highest set block of each column. Pixel x, y is column x, y;
+// columns without blocks are transparent.
+func Overview(world *blockworld.Blockworld, opts OverviewOptions) *image.RGBA {
+	sx, sy, sz := world.Size()
+	img := image.NewRGBA(image.Rect(0, 0, sx, sy))
+	for y := 0; y < sy; y++ {
+		for x := 0; x < sx; x++ {
+			for z := sz - 1; z >= 0; z-- {
+				b, ok := world.GetRaw(x, y, z)
+				if !ok {
+					continue
+				}
+				t := 1.
+				if sz > 1 {
+					t = float64(z) / float64(sz-1)
+				}
+				img.SetRGBA(x, y, overviewColor(b.Color, t, opts))
+				break
+			}
+		}
+	}
+	return img
+}
+
+func overviewColor(c color.NRGBA, t float64, opts OverviewOptions) color.RGBA {
+	if opts.Colormap != nil {
+		v := opts.Colormap(t)
+		return color.RGBA{R: uint8(v.X * 255), G: uint8(v.Y * 255), B: uint8(v.Z * 255), A: 255}
+	}
+	// Like in frames, the alpha of VXL colors darkens the block.
+	cr, cg, cb, _ := c.RGBA()
+	f := 1.
+	if opts.Shade {
+		f = 0.4 + 0.6*t
+	}
+	return color.RGBA{
+		R: uint8(float64(cr>>8) * f),
+		G: uint8(float64(cg>>8) * f),
+		B: uint8(float64(cb>>8) * f),
+		A: 255,
+	}
+}
@@ -0,0 +1,68 @@
+package render_test
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/pudelkoM/go-render/pkg/blockworld"
+	"github.com/pudelkoM/go-render/pkg/render"
+)
+
+func TestOverview(t *testing.T) {
+	red := color.NRGBA{R: 200, A: 255}
+	green := color.NRGBA{G: 200, A: 255}
+	world := blockworld.NewBlockworld()
+	world.SetSize(3, 2, 5)
+	world.Set(0, 0, 0, blockworld.Block{Color: red})
+	world.Set(1, 0, 0, blockworld.Block{Color: red})
+	world.Set(1, 0, 4, blockworld.Block{Color: green})
+	world.Set(2, 1, 2, blockworld.Block{Color: color.NRGBA{B: 200, A: 127}})
+
+	tests := []struct {
+		name string
+		opts render.OverviewOptions
+		want map[[2]int]color.RGBA
+	}{
+		{
+			name: "colors",
+			want: map[[2]int]color.RGBA{
+				{0, 0}: {R: 200, A: 255},
+				{1, 0}: {G: 200, A: 255},
+				{2, 0}: {},
+				{2, 1}: {B: 99, A: 255}, // darkened by the VXL alpha
+			},
+		},
+		{
+			name: "shaded",
+			opts: render.OverviewOptions{Shade: true},
+			want: map[[2]int]color.RGBA{
+				{0, 0}: {R: 80, A: 255},
+				{1, 0}: {G: 200, A: 255},
+				{2, 0}: {},
+			},
+		},
+		{
+			name: "colormap",
+			opts: render.OverviewOptions{Colormap: func(t float64) blockworld.Vec3 { return blockworld.Vec3{X: t, Y: 1 - t} }},
+			want: map[[2]int]color.RGBA{
+				{0, 0}: {G: 255, A: 255},
+				{1, 0}: {R: 255, A: 255},
+				{2, 1}: {R: 127, G: 127, A: 255},
+				{2, 0}: {},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := render.Overview(world, tt.opts)
+			if b := img.Bounds(); b.Dx() != 3 || b.Dy() != 2 {
+				t.Fatalf("bounds = %v, want 3x2", b)
+			}
+			for p, want := range tt.want {
+				if got := img.RGBAAt(p[0], p[1]); got != want {
+					t.Errorf("pixel %v = %v, want %v", p, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package render
+
+import (
+	"math"
+
+	"github.com/pudelkoM/go-render/pkg/blockworld"
+)
+
+// hit describes the first set block a ray ran into.
+type hit struct {
+	block  *blockworld.Block
+	steps  int             // number of voxels traversed before the hit
+	normal blockworld.Vec3 // of the face the ray entered the block through
+	dist   float64         // from the ray origin to the face, in ray lengths
+}
+
+// faceDist returns the distance along the ray from rayPos along rayDir to
+// the face of block p it enters moving step along axis.
+func faceDist(rayPos, rayDir blockworld.Vec3, p blockworld.Point, axis, step int) float64 {
+	face := float64([3]int{p.X, p.Y, p.Z}[axis])
+	if step < 0 {
+		face++
+	}
+	return (face - [3]float64{rayPos.X, rayPos.Y, rayPos.Z}[axis]) / [3]float64{rayDir.X, rayDir.Y, rayDir.Z}[axis]
+}
+
+// faceNormal returns the normal of the face a ray moving step along axis,
+// 0 to 2 for X to Z, enters a block through.
+func faceNormal(axis, step int) blockworld.Vec3 {
+	var n [3]float64
+	n[axis] = float64(-step)
+	return blockworld.Vec3{X: n[0], Y: n[1], Z: n[2]}
+}
+
+// castRayAmatidesWoo walks the voxel grid from rayPos along rayDir using the
+// Amanatides & Woo traversal and returns the first set block. Air with a
+// DistanceToNearestBlock, see Blockworld.ComputeDistances, is crossed in a
+// single iteration, which does not change the result.
+func castRayAmatidesWoo(world *blockworld.Blockworld, rayPos, rayDir blockworld.Vec3,
+	maxSteps int) (hit, bool) {
+	fn := func(pos, dir float64) (int, float64, float64) {
+		if dir > 0 {
+			return 1, 1 / dir, (math.Floor(pos+1) - pos) / dir
+		} else if dir < 0 {
+			return -1, 1 / -dir, (math.Ceil(pos-1) - pos) / dir
+		} else {
+			return 0, 0, math.Inf(1)
+		}
+	}
+
+	origin := rayPos
+	stepX, tDeltaX, tMaxX := fn(rayPos.X, rayDir.X)
+	stepY, tDeltaY, tMaxY := fn(rayPos.Y, rayDir.Y)
+	stepZ, tDeltaZ, tMaxZ := fn(rayPos.Z, rayDir.Z)
+
+	// The axis stepped last tells which face of the block the ray entered.
+	axis := 0
+	for i := 0; i < maxSteps; i++ {
+		if tMaxX < tMaxY && tMaxX < tMaxZ {
+			rayPos.X += float64(stepX)
+			tMaxX += tDeltaX
+			axis = 0
+		} else if tMaxY < tMaxZ {
+			rayPos.Y += float64(stepY)
+			tMaxY += tDeltaY
+			axis = 1
+		} else {
+			rayPos.Z += float64(stepZ)
+			tMaxZ += tDeltaZ
+			axis = 2
+		}
+
+		n := rayPos.ToPointFloor()
+		b, ok := world.Get(n)
+		if !ok {
+			if b == nil && leavingWorld(world, n, stepX, stepY, stepZ) {
+				return hit{}, false
+			}
+			var d int16
+			if b != nil {
+				d = b.DistanceToNearestBlock
+			}
+			if d == 0 {
+				// Outside of the world, in an unallocated chunk or unknown.
+				d = world.Distance(n)
+			}
+			if d > 1 {
+				// The ray is inside a cube of air reaching d-1 blocks in
+				// every direction. Take all grid steps until it leaves the
+				// cube at once, the next iteration steps out of it. They
+				// still count as steps, so the view distance stays the same.
+				skip := float64(d - 1)
+				tExit := min(tMaxX+skip*tDeltaX, tMaxY+skip*tDeltaY, tMaxZ+skip*tDeltaZ)
+				var nX, nY, nZ int
+				rayPos.X, tMaxX, nX = skipSteps(rayPos.X, tMaxX, tDeltaX, stepX, tExit)
+				rayPos.Y, tMaxY, nY = skipSteps(rayPos.Y, tMaxY, tDeltaY, stepY, tExit)
+				rayPos.Z, tMaxZ, nZ = skipSteps(rayPos.Z, tMaxZ, tDeltaZ, stepZ, tExit)
+				i += nX + nY + nZ
+			}
+			continue
+		}
+		step := [3]int{stepX, stepY, stepZ}[axis]
+		return hit{block: b, steps: i, normal: faceNormal(axis, step), dist: faceDist(origin, rayDir, n, axis, step)}, true
+	}
+	return hit{}, false
+}
+
+// skipSteps takes all grid steps along one axis that happen before tExit
+// and returns the new position, tMax and number of steps. The step at
+// exactly tExit is left to the caller.
+func skipSteps(pos, tMax, tDelta float64, step int, tExit float64) (float64, float64, int) {
+	if step == 0 || tMax >= tExit {
+		return pos, tMax, 0
+	}
+	n := math.Ceil((tExit - tMax) / tDelta)
+	if tMax+(n-1)*tDelta >= tExit {
+		// Rounding overshot, this would take the step at tExit as well.
+		n--
+	}
+	return pos + n*float64(step), tMax + n*tDelta, int(n)
+}
+
+// leavingWorld reports whether a ray at p outside of world moves away from it
+// on some axis and can therefore never hit a block.
+func leavingWorld(world *blockworld.Blockworld, p blockworld.Point, stepX, stepY, stepZ int) bool {
+	sx, sy, sz := world.Size()
+	return (p.X < 0 && stepX <= 0) || (p.X >= sx && stepX >= 0) ||
+		(p.Y < 0 && stepY <= 0) || (p.Y >= sy && stepY >= 0) ||
+		(p.Z < 0 && stepZ <= 0) || (p.Z >= sz && stepZ >= 0)
+}
@@ -0,0 +1,85 @@
+package render
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/pudelkoM/go-render/pkg/blockworld"
+)
+
+func TestDistanceSkipping(t *testing.T) {
+	for _, chunked := range []bool{false, true} {
+		rng := rand.New(rand.NewSource(2))
+		world := sparseWorld(rng)
+		if chunked {
+			// Rays starting beyond x = 48 or y = 40 cross unallocated
+			// chunks before they hit anything.
+			world = chunkedCopy(world, 96, 80, 48)
+		}
+
+		type ray struct{ pos, dir blockworld.Vec3 }
+		var rays []ray
+		var want []hit
+		for i := 0; i < 20000; i++ {
+			r := ray{
+				pos: blockworld.Vec3{X: rng.Float64() * 60, Y: rng.Float64() * 52, Z: rng.Float64() * 30},
+				dir: blockworld.Vec3{X: rng.NormFloat64(), Y: rng.NormFloat64(), Z: rng.NormFloat64()}.Normalize(),
+			}
+			h, _ := castRayAmatidesWoo(world, r.pos, r.dir, 200)
+			rays, want = append(rays, r), append(want, h)
+		}
+
+		world.ComputeDistances()
+		for i, r := range rays {
+			got, _ := castRayAmatidesWoo(world, r.pos, r.dir, 200)
+			if got != want[i] {
+				t.Fatalf("chunked %v: ray %v along %v: got %p after %d steps, want %p after %d steps",
+					chunked, r.pos, r.dir, got.block, got.steps, want[i].block, want[i].steps)
+			}
+		}
+	}
+}
+
+// chunkedCopy returns a chunked world of sx*sy*sz blocks with the blocks of
+// the smaller world, so the chunks beyond them stay unallocated.
+func chunkedCopy(world *blockworld.Blockworld, sx, sy, sz int) *blockworld.Blockworld {
+	c := blockworld.NewChunkedBlockworld()
+	c.SetSize(sx, sy, sz)
+	for p, b := range world.All() {
+		if b.IsSet() {
+			c.Set(p.X, p.Y, p.Z, *b)
+		}
+	}
+	return c
+}
+
+func TestHitFace(t *testing.T) {
+	world := blockworld.NewBlockworld()
+	world.SetSize(8, 8, 8)
+	world.Set(4, 4, 4, blockworld.Block{})
+	center := blockworld.Vec3{X: 4.5, Y: 4.5, Z: 4.5}
+	for _, want := range []blockworld.Vec3{
+		{X: 1}, {X: -1}, {Y: 1}, {Y: -1}, {Z: 1}, {Z: -1},
+	} {
+		// Aim at the face from slightly off its center axis.
+		pos := center.Add(want.Mul(3)).Add(blockworld.Vec3{X: 0.1, Y: 0.2, Z: 0.3})
+		dir := center.Sub(pos).Normalize()
+		h, ok := castRayAmatidesWoo(world, pos, dir, 20)
+		if !ok || h.normal != want {
+			t.Errorf("ray from %v: normal %v, %v, want %v", pos, h.normal, ok, want)
+			continue
+		}
+		// The hit point lies on the face.
+		if d := pos.Add(dir.Mul(h.dist)).Sub(center).Dot(want); math.Abs(d-0.5) > 1e-9 {
+			t.Errorf("ray from %v: hit point %g from the center, want 0.5", pos, d)
+		}
+	}
+}
+
+// clearDistances resets the distance field of world.
+func clearDistances(world *blockworld.Blockworld) {
+	for _, b := range world.All() {
+		b.DistanceToNearestBlock = 0
+	}
+}
@@ -0,0 +1,304 @@
+// Package render implements the voxel ray caster shared by the viewer and tools.
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sync"
+
+	"github.com/pudelkoM/go-render/pkg/blockworld"
+)
+
+// Mode selects what the renderer writes into a frame.
+type Mode int
+
+const (
+	ModeNormal Mode = iota // block colors
+	ModeDepth              // traversal steps mapped through the Magma colormap
+	ModeAO                 // the ambient occlusion term in gray, see Options.AmbientOcclusion
+)
+
+// Traversal selects how rays find the first set block.
+type Traversal int
+
+const (
+	TraversalGrid   Traversal = iota // voxel by voxel, see castRayAmatidesWoo
+	TraversalOctree                  // skipping empty space with an Octree
+)
+
+// Options controls how a frame is rendered.
+type Options struct {
+	Mode      Mode
+	Traversal Traversal
+	MaxSteps  int // maximum number of voxels a ray traverses
+	Threads   int // number of goroutines, each rendering a horizontal band
+
+	// Lighting shades the faces of blocks by how directly they face the sun,
+	// from full brightness down to Ambient for faces turned away from it.
+	Lighting bool
+	Sun      blockworld.Angle3 // direction towards the sun
+	Ambient  float64           // brightness of unlit faces, 0 to 1
+	// Shadows, together with Lighting, casts a ray from every hit towards
+	// the sun and leaves the face unlit if a block closer than
+	// ShadowDistance is in the way.
+	Shadows        bool
+	ShadowDistance float64
+	// AmbientOcclusion darkens faces towards the corners and edges they
+	// share with neighboring blocks.
+	AmbientOcclusion bool
+	// Reflective blocks mirror the scene: Reflectivity is how much of their
+	// color comes from the mirrored ray, from 0 to 1. MaxBounces limits how
+	// many reflections a ray follows, 0 turns them off.
+	Reflectivity float64
+	MaxBounces   int
+}
+
+// DefaultReflectivity and DefaultMaxBounces are the settings that turning on
+// reflections uses. DefaultOptions leaves them off.
+const (
+	DefaultReflectivity = 0.4
+	DefaultMaxBounces   = 2
+)
+
+func DefaultOptions() Options {
+	return Options{
+		Mode:             ModeNormal,
+		Traversal:        TraversalGrid,
+		MaxSteps:         250,
+		Threads:          4,
+		Lighting:         false,
+		Sun:              blockworld.Angle3{Theta: 40, Phi: 120},
+		Ambient:          0.4,
+		Shadows:          false,
+		ShadowDistance:   100,
+		AmbientOcclusion: false,
+		Reflectivity:     0,
+		MaxBounces:       0,
+	}
+}
+
+// Renderer casts one ray per pixel from Camera into World.
+type Renderer struct {
+	World   *blockworld.Blockworld
+	Camera  Camera
+	Options Options
+	// Octree is built from World by the first render with TraversalOctree.
+	// Set it to nil after modifying World to have it rebuilt.
+	Octree *Octree
+}
+
+func NewRenderer(world *blockworld.Blockworld, camera Camera, opts Options) *Renderer {
+	return &Renderer{
+		World:   world,
+		Camera:  camera,
+		Options: opts,
+	}
+}
+
+// Frame renders into a newly allocated w x h image.
+func (r *Renderer) Frame(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	r.Render(img)
+	return img
+}
+
+// Render renders into img, overwriting all of its pixels.
+func (r *Renderer) Render(img *image.RGBA) {
+	// clear image
+	draw.Draw(img, img.Rect, image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+
+	s := r.scene()
+
+	threads := max(r.Options.Threads, 1)
+	yDD := int(math.Ceil(float64(height) / float64(threads)))
+	wg := sync.WaitGroup{}
+	wg.Add(threads)
+	for t := 0; t < threads; t++ {
+		go func(t int) {
+			defer wg.Done()
+			yStart := t * yDD
+			if yStart >= height {
+				return
+			}
+			yEnd := (t + 1) * yDD
+			if yEnd >= height {
+				yEnd = height
+			}
+
+			for y := yStart; y < yEnd; y++ {
+				for x := 0; x < width; x++ {
+					rayPos := r.Camera.Origin(x, y, width, height)
+					rayVec := r.Camera.Ray(x, y, width, height)
+					h, ok := s.castRay(rayPos, rayVec, r.Options.MaxSteps)
+					if !ok {
+						continue
+					}
+					img.SetRGBA(img.Rect.Min.X+x, img.Rect.Min.Y+y, s.shade(rayPos, rayVec, h))
+				}
+			}
+		}(t)
+	}
+	wg.Wait()
+}
+
+// scene is what shading the pixels of a frame needs.
+type scene struct {
+	opts    Options
+	world   *blockworld.Blockworld
+	castRay func(rayPos, rayDir blockworld.Vec3, maxSteps int) (hit, bool)
+	sun     blockworld.Vec3 // unit vector towards the sun
+}
+
+// scene prepares rendering a frame with the current options.
+func (r *Renderer) scene() *scene {
+	s := &scene{
+		opts:  r.Options,
+		world: r.World,
+		castRay: func(rayPos, rayDir blockworld.Vec3, maxSteps int) (hit, bool) {
+			return castRayAmatidesWoo(r.World, rayPos, rayDir, maxSteps)
+		},
+		sun: r.Options.Sun.ToCartesianVec3(1),
+	}
+	if r.Options.Traversal == TraversalOctree {
+		if r.Octree == nil || r.Octree.world != r.World {
+			r.Octree = NewOctree(r.World)
+		}
+		s.castRay = r.Octree.castRay
+	}
+	return s
+}
+
+// shade turns the hit of the ray from rayPos along rayDir into the pixel
+// color for the current mode.
+func (s *scene) shade(rayPos, rayDir blockworld.Vec3, h hit) color.RGBA {
+	switch s.opts.Mode {
+	case ModeDepth:
+		v := blockworld.MagmaClamp(float64(h.steps) / float64(s.opts.MaxSteps))
+		return color.RGBA{
+			R: uint8(v.X * 255),
+			G: uint8(v.Y * 255),
+			B: uint8(v.Z * 255),
+			A: 255,
+		}
+	case ModeAO:
+		v := uint8(s.occlusion(rayPos.Add(rayDir.Mul(h.dist)), h) * 255)
+		return color.RGBA{v, v, v, 255}
+	}
+
+	cr, cg, cb, ca := s.composite(rayPos, rayDir, h, s.opts.MaxBounces)
+	return color.RGBA{uint8(cr >> 8), uint8(cg >> 8), uint8(cb >> 8), uint8(ca >> 8)}
+}
+
+// composite returns the color seen along the ray from rayPos along rayDir,
+// which first hits h, in the components returned by surface. Transparent
+// blocks are composited front to back with the blocks behind them, until
+// the color is opaque or the ray has taken MaxSteps steps.
+func (s *scene) composite(rayPos, rayDir blockworld.Vec3, h hit, bounces int) (cr, cg, cb, ca uint32) {
+	if !h.block.Transparent() {
+		return s.surface(rayPos, rayDir, h, bounces)
+	}
+	var r, g, b, a float64 // a goes from 0 to 1, the rest is premultiplied
+	steps := s.opts.MaxSteps
+	for {
+		sr, sg, sb, sa := s.surface(rayPos, rayDir, h, bounces)
+		t := 1 - a // how much light makes it through the blocks in front
+		r += t * float64(sr)
+		g += t * float64(sg)
+		b += t * float64(sb)
+		a += t * float64(sa) / 0xffff
+		if !h.block.Transparent() {
+			break
+		}
+		steps -= h.steps + 1
+		if a >= 1 || steps <= 0 {
+			break
+		}
+		// Continue from just inside of the block, which the traversal skips
+		// as the one the ray starts in.
+		rayPos = rayPos.Add(rayDir.Mul(h.dist + 1e-3))
+		var ok bool
+		if h, ok = s.castRay(rayPos, rayDir, steps); !ok {
+			break
+		}
+	}
+	return uint32(r), uint32(g), uint32(b), uint32(a * 0xffff)
+}
+
+// surface returns the color of the hit of the ray from rayPos along rayDir
+// in ModeNormal, as premultiplied 16 bit components like color.Color.RGBA,
+// following up to bounces reflections.
+func (s *scene) surface(rayPos, rayDir blockworld.Vec3, h hit, bounces int) (cr, cg, cb, ca uint32) {
+	// Color-space conversion without interfaces and heap allocations.
+	cr, cg, cb, ca = h.block.Color.RGBA()
+	if s.opts.Lighting || s.opts.AmbientOcclusion {
+		light := 1.
+		if s.opts.Lighting {
+			light = s.light(rayPos, rayDir, h)
+		}
+		if s.opts.AmbientOcclusion {
+			light *= s.occlusion(rayPos.Add(rayDir.Mul(h.dist)), h)
+		}
+		cr = uint32(float64(cr) * light)
+		cg = uint32(float64(cg) * light)
+		cb = uint32(float64(cb) * light)
+	}
+	if h.block.Reflective() && bounces > 0 && s.opts.Reflectivity > 0 {
+		// Mirror the ray on the face. Reflections of the background, which
+		// has no color of its own, leave the surface as it is.
+		from := rayPos.Add(rayDir.Mul(h.dist)).Add(h.normal.Mul(1e-3))
+		dir := rayDir.Sub(h.normal.Mul(2 * rayDir.Dot(h.normal)))
+		if rh, ok := s.castRay(from, dir, s.opts.MaxSteps); ok {
+			rr, rg, rb, _ := s.composite(from, dir, rh, bounces-1)
+			k := s.opts.Reflectivity
+			cr = uint32(float64(cr)*(1-k) + float64(rr)*k)
+			cg = uint32(float64(cg)*(1-k) + float64(rg)*k)
+			cb = uint32(float64(cb)*(1-k) + float64(rb)*k)
+		}
+	}
+	return cr, cg, cb, ca
+}
+
+// light returns the brightness of the face hit by the ray from rayPos along
+// rayDir: the Lambertian reflection of the sun on top of the ambient light.
+func (s *scene) light(rayPos, rayDir blockworld.Vec3, h hit) float64 {
+	ambient := s.opts.Ambient
+	sun := h.normal.Dot(s.sun)
+	if sun <= 0 {
+		return ambient
+	}
+	if s.opts.Shadows {
+		sun *= s.sunlight(rayPos.Add(rayDir.Mul(h.dist)), h.normal)
+	}
+	return ambient + (1-ambient)*sun
+}
+
+// sunlight returns how much of the sun reaches point p on a face with normal
+// n, from 0 behind an opaque block to 1 in the open. Transparent blocks in
+// the way let through what their alpha does not cover.
+func (s *scene) sunlight(p, n blockworld.Vec3) float64 {
+	// Start just in front of the face, in the air block next to it, and take
+	// enough steps to cross ShadowDistance in any direction.
+	maxDist := s.opts.ShadowDistance
+	steps := int(maxDist*math.Sqrt(3)) + 3
+	pos := p.Add(n.Mul(1e-3))
+	light := 1.
+	for light > 0 && steps > 0 {
+		h, ok := s.castRay(pos, s.sun, steps)
+		if !ok || h.dist > maxDist {
+			break
+		}
+		if !h.block.Transparent() {
+			return 0
+		}
+		light *= 1 - float64(h.block.Color.A)/255
+		// Continue from just inside of the block, like composite.
+		pos = pos.Add(s.sun.Mul(h.dist + 1e-3))
+		maxDist -= h.dist + 1e-3
+		steps -= h.steps + 1
+	}
+	return light
+}
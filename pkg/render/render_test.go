@@ -0,0 +1,195 @@
+package render
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/pudelkoM/go-render/pkg/blockworld"
+	"github.com/pudelkoM/go-render/pkg/maploader"
+)
+
+func TestShadows(t *testing.T) {
+	// A floor with its top at z = 2 and a block hanging above it, which
+	// shades the floor at 8, 8 from a sun 45° above +X.
+	world := blockworld.NewBlockworld()
+	world.SetSize(16, 16, 16)
+	for x := 1; x < 15; x++ {
+		for y := 1; y < 15; y++ {
+			world.Set(x, y, 1, blockworld.Block{})
+		}
+	}
+	stone := blockworld.Block{}
+	glass := blockworld.Block{Color: color.NRGBA{B: 255, A: 102}, Flags: blockworld.FlagTransparent}
+
+	opts := DefaultOptions()
+	opts.Lighting = true
+	opts.Sun = blockworld.Angle3{Theta: 45, Phi: 0}
+	lit := opts.Ambient + (1-opts.Ambient)*math.Sqrt(0.5)
+	down := blockworld.Vec3{Z: -1}
+
+	for _, tt := range []struct {
+		name     string
+		shadows  bool
+		distance float64
+		x        float64
+		blocker  blockworld.Block
+		want     float64
+	}{
+		{"off", false, 100, 8.5, stone, lit},
+		{"shaded", true, 100, 8.5, stone, opts.Ambient},
+		{"open", true, 100, 4.5, stone, lit},
+		{"out of reach", true, 1, 8.5, stone, lit},
+		// The glass covers 40% of the sun.
+		{"behind glass", true, 100, 8.5, glass, opts.Ambient + (1-opts.Ambient)*math.Sqrt(0.5)*0.6},
+	} {
+		world.Set(10, 8, 3, tt.blocker)
+		opts.Shadows = tt.shadows
+		opts.ShadowDistance = tt.distance
+		for _, traversal := range []Traversal{TraversalGrid, TraversalOctree} {
+			opts.Traversal = traversal
+			s := NewRenderer(world, Camera{}, opts).scene()
+			pos := blockworld.Vec3{X: tt.x, Y: 8.5, Z: 10}
+			h, ok := s.castRay(pos, down, 100)
+			if !ok {
+				t.Fatalf("%s: ray missed the floor", tt.name)
+			}
+			if got := s.light(pos, down, h); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("%s, traversal %d: light %g, want %g", tt.name, traversal, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestReflections(t *testing.T) {
+	// A reflective blue floor with its top at z = 2 and a red wall at
+	// x = 10, which a ray going down at 45° towards +X sees in the floor.
+	world := blockworld.NewBlockworld()
+	world.SetSize(16, 16, 16)
+	for x := 1; x < 15; x++ {
+		for y := 1; y < 15; y++ {
+			world.Set(x, y, 1, blockworld.Block{Color: color.NRGBA{B: 255, A: 255}, Flags: blockworld.FlagReflective})
+		}
+	}
+	for y := 1; y < 15; y++ {
+		for z := 2; z < 10; z++ {
+			world.Set(10, y, z, blockworld.Block{Color: color.NRGBA{R: 255, A: 255}})
+		}
+	}
+	toWall := blockworld.Vec3{X: 1, Z: -1}.Normalize()
+	toSky := blockworld.Vec3{X: -1, Z: -1}.Normalize()
+	blue := color.RGBA{B: 255, A: 255}
+
+	for _, tt := range []struct {
+		name         string
+		pos, dir     blockworld.Vec3
+		reflectivity float64
+		bounces      int
+		want         color.RGBA
+	}{
+		{"reflection", blockworld.Vec3{X: 4.5, Y: 8.5, Z: 5}, toWall, 0.5, 1, color.RGBA{R: 127, B: 127, A: 255}},
+		{"stronger reflection", blockworld.Vec3{X: 4.5, Y: 8.5, Z: 5}, toWall, 0.75, 1, color.RGBA{R: 191, B: 63, A: 255}},
+		{"no bounces", blockworld.Vec3{X: 4.5, Y: 8.5, Z: 5}, toWall, 0.5, 0, blue},
+		{"not reflective", blockworld.Vec3{X: 4.5, Y: 8.5, Z: 5}, toWall, 0, 1, blue},
+		{"background", blockworld.Vec3{X: 9.5, Y: 8.5, Z: 5}, toSky, 0.5, 1, blue},
+	} {
+		for _, traversal := range []Traversal{TraversalGrid, TraversalOctree} {
+			opts := DefaultOptions()
+			opts.Traversal = traversal
+			opts.Reflectivity = tt.reflectivity
+			opts.MaxBounces = tt.bounces
+			s := NewRenderer(world, Camera{}, opts).scene()
+			h, ok := s.castRay(tt.pos, tt.dir, 100)
+			if !ok {
+				t.Fatalf("%s: ray missed the floor", tt.name)
+			}
+			if got := s.shade(tt.pos, tt.dir, h); got != tt.want {
+				t.Errorf("%s, traversal %d: %v, want %v", tt.name, traversal, got, tt.want)
+			}
+		}
+	}
+}
+
+// BenchmarkShadows renders a frame of DragonsReach.vxl from the viewer's
+// start position without lighting, with lighting and with shadows.
+func BenchmarkShadows(b *testing.B) {
+	world := blockworld.NewBlockworld()
+	if err := maploader.LoadMap("../../maps/DragonsReach.vxl", world); err != nil {
+		b.Skip(err)
+	}
+	camera := Camera{Pos: blockworld.Vec3{X: 190, Y: 310, Z: 33}, Dir: blockworld.Angle3{Theta: 95, Phi: 325}}
+	for _, tt := range []struct {
+		name              string
+		lighting, shadows bool
+	}{
+		{"unlit", false, false},
+		{"lighting", true, false},
+		{"shadows", true, true},
+	} {
+		b.Run(tt.name, func(b *testing.B) {
+			opts := DefaultOptions()
+			opts.Lighting = tt.lighting
+			opts.Shadows = tt.shadows
+			r := NewRenderer(world, camera, opts)
+			img := r.Frame(640, 480)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				r.Render(img)
+			}
+		})
+	}
+}
+
+func TestTransparency(t *testing.T) {
+	// Glass panes at x = 4 and 5 in front of a red wall at x = 8, seen
+	// along +X.
+	glass := func(world *blockworld.Blockworld, x int, a uint8, flags blockworld.BlockFlags) {
+		for y := 1; y < 15; y++ {
+			for z := 1; z < 15; z++ {
+				world.Set(x, y, z, blockworld.Block{Color: color.NRGBA{B: 255, A: a}, Flags: flags})
+			}
+		}
+	}
+	world := func(panes int, flags blockworld.BlockFlags) *blockworld.Blockworld {
+		world := blockworld.NewBlockworld()
+		world.SetSize(16, 16, 16)
+		for i := 0; i < panes; i++ {
+			glass(world, 4+i, 128, flags)
+		}
+		for y := 1; y < 15; y++ {
+			for z := 1; z < 15; z++ {
+				world.Set(8, y, z, blockworld.Block{Color: color.NRGBA{R: 255, A: 255}})
+			}
+		}
+		return world
+	}
+
+	for _, tt := range []struct {
+		name     string
+		world    *blockworld.Blockworld
+		maxSteps int
+		want     color.RGBA
+	}{
+		{"wall", world(0, blockworld.FlagTransparent), 100, color.RGBA{R: 255, A: 255}},
+		// Half of the light comes from the wall.
+		{"one pane", world(1, blockworld.FlagTransparent), 100, color.RGBA{R: 127, B: 128, A: 255}},
+		{"two panes", world(2, blockworld.FlagTransparent), 100, color.RGBA{R: 63, B: 192, A: 255}},
+		{"opaque pane", world(1, 0), 100, color.RGBA{B: 128, A: 128}},
+		{"wall out of reach", world(1, blockworld.FlagTransparent), 5, color.RGBA{B: 128, A: 128}},
+	} {
+		for _, traversal := range []Traversal{TraversalGrid, TraversalOctree} {
+			opts := DefaultOptions()
+			opts.Traversal = traversal
+			opts.MaxSteps = tt.maxSteps
+			s := NewRenderer(tt.world, Camera{}, opts).scene()
+			pos, dir := blockworld.Vec3{X: 1.5, Y: 8.5, Z: 8.5}, blockworld.Vec3{X: 1}
+			h, ok := s.castRay(pos, dir, tt.maxSteps)
+			if !ok {
+				t.Fatalf("%s: ray missed", tt.name)
+			}
+			if got := s.shade(pos, dir, h); got != tt.want {
+				t.Errorf("%s, traversal %d: %v, want %v", tt.name, traversal, got, tt.want)
+			}
+		}
+	}
+}